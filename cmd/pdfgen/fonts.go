@@ -0,0 +1,62 @@
+package main
+
+import (
+	"deliveries-pdf/internal/pdf"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runFontsCommand prints how fonts are chosen with the user's settings, and which of
+// them cover the characters of an input file. It returns the exit code.
+func runFontsCommand(args []string) int {
+	flags := flag.NewFlagSet("fonts", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: pdfgen fonts [flags] [input file]")
+		fmt.Fprintln(flags.Output(), "Shows the fonts found, the ones chosen and their coverage of the input file.")
+		flags.PrintDefaults()
+	}
+	system := flags.Bool("system", false, "use the system fonts, as with system = true in [fonts]")
+	family := flags.String("family", "", "system font family to try first")
+	file := flags.String("file", "", "font file or collection to draw with")
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+
+	settings, warnings, err := pdf.LoadUserSettings()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+
+	config := settings.Config
+	if *system {
+		config.Fonts.System = true
+	}
+	if *family != "" {
+		config.Fonts.Family = *family
+	}
+	if *file != "" {
+		config.Fonts.File = *file
+	}
+
+	sample := ""
+	if flags.NArg() > 0 {
+		data, err := os.ReadFile(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not read %s: %v\n", flags.Arg(0), err)
+			return 1
+		}
+		sample = string(data)
+	}
+
+	if err := pdf.WriteFontReport(os.Stdout, config, sample); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -5,6 +5,10 @@ import (
 	"deliveries-pdf/internal/theme"
 	"fmt"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -16,6 +20,11 @@ import (
 )
 
 func main() {
+	// Subcommands run without opening the window
+	if len(os.Args) > 1 && os.Args[1] == "fonts" {
+		os.Exit(runFontsCommand(os.Args[2:]))
+	}
+
 	// Create a local random generator
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 
@@ -25,6 +34,12 @@ func main() {
 
 	myApp.Settings().SetTheme(theme.CreateRandomTheme(r))
 
+	// Use the shop's own settings and entry template when it has them
+	settings, settingsWarnings, settingsErr := pdf.LoadUserSettings()
+	config := settings.Config
+	template, templateErr := pdf.LoadUserTemplate()
+	config.Template = template
+
 	zoneEntry := widget.NewEntry()
 	zoneEntry.SetPlaceHolder("Mankaiza mankaiza zoky ?")
 	zoneEntry.TextStyle = fyne.TextStyle{Bold: true}
@@ -33,43 +48,305 @@ func main() {
 	contentEntry.SetPlaceHolder("Merci monsieur la Parole de m'avoir donné le Jury")
 	contentEntry.Wrapping = fyne.TextWrapWord
 
+	printerEntry := widget.NewEntry()
+	printerEntry.SetPlaceHolder("tcp://192.168.1.50:9100 na /dev/usb/lp0")
+
+	// Details of the round printed under the zone, the pasted header lines fill the empty ones
+	dateEntry := widget.NewEntry()
+	dateEntry.SetPlaceHolder(time.Now().Format("02/01/2006"))
+	courierEntry := widget.NewEntry()
+	courierEntry.SetPlaceHolder("Anaran'ny livreur")
+	courierPhoneEntry := widget.NewEntry()
+	courierPhoneEntry.SetPlaceHolder("034 00 000 00")
+	shopEntry := widget.NewEntry()
+	shopEntry.SetPlaceHolder("Anaran'ny fivarotana")
+	shopContactEntry := widget.NewEntry()
+	shopContactEntry.SetPlaceHolder("032 00 000 00")
+	headerNotesEntry := widget.NewEntry()
+	headerNotesEntry.SetPlaceHolder("Fanamarihana ho an'ny livreur")
+
+	detailsContainer := container.NewGridWithColumns(4,
+		widget.NewLabel("Daty:"), dateEntry,
+		widget.NewLabel("Livreur:"), courierEntry,
+		widget.NewLabel("Laharan'ny livreur:"), courierPhoneEntry,
+		widget.NewLabel("Fivarotana:"), shopEntry,
+		widget.NewLabel("Laharan'ny fivarotana:"), shopContactEntry,
+		widget.NewLabel("Fanamarihana:"), headerNotesEntry,
+	)
+
 	formContainer := container.NewVBox(
 		widget.NewLabelWithStyle("Trasy:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 		zoneEntry,
+		detailsContainer,
 		widget.NewLabelWithStyle("Colleo eto le tany @ rossy:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 	)
 
+	// applyForm sets the details filled in the form over the pasted header lines, the
+	// zone field only naming the entries pasted without one
+	applyForm := func(manifest *pdf.Manifest) error {
+		if manifest.Zone == "" {
+			manifest.Zone = strings.TrimSpace(zoneEntry.Text)
+		}
+		for _, field := range []struct {
+			value  string
+			target *string
+		}{
+			{courierEntry.Text, &manifest.Courier},
+			{courierPhoneEntry.Text, &manifest.CourierPhone},
+			{shopEntry.Text, &manifest.Shop},
+			{shopContactEntry.Text, &manifest.ShopContact},
+			{headerNotesEntry.Text, &manifest.Notes},
+		} {
+			if value := strings.TrimSpace(field.value); value != "" {
+				*field.target = value
+			}
+		}
+		if date := strings.TrimSpace(dateEntry.Text); date != "" {
+			var err error
+			if manifest.Date, err = pdf.ParseDate(date); err != nil {
+				return err
+			}
+		}
+
+		if manifest.Zone == "" || len(manifest.Entries) == 0 {
+			return fmt.Errorf("mba fenoy tsara pr aloha (par respect)")
+		}
+		return nil
+	}
+
+	// showSkipped tells which header lines of the pasted content were not understood
+	showSkipped := func(warnings []string) {
+		if len(warnings) > 0 {
+			dialog.ShowInformation("Fampitandremana", strings.Join(warnings, "\n"), myWindow)
+		}
+	}
+
+	// readManifest builds the manifest from the pasted content, the fields of the form
+	// taking precedence over its header lines
+	readManifest := func() (*pdf.Manifest, error) {
+		manifest, warnings, err := pdf.ParseManifest(contentEntry.Text)
+		if err != nil {
+			return nil, err
+		}
+		showSkipped(warnings)
+		if zone := strings.TrimSpace(zoneEntry.Text); zone != "" {
+			manifest.Zone = zone
+		}
+		if err := applyForm(manifest); err != nil {
+			return nil, err
+		}
+		return manifest, nil
+	}
+
+	// readBatch builds one manifest per zone of the pasted content
+	readBatch := func() ([]*pdf.Manifest, error) {
+		manifests, warnings, err := pdf.ParseBatch(contentEntry.Text)
+		if err != nil {
+			return nil, err
+		}
+		showSkipped(warnings)
+		if len(manifests) == 0 {
+			return nil, fmt.Errorf("mba fenoy tsara pr aloha (par respect)")
+		}
+		for _, manifest := range manifests {
+			if err := applyForm(manifest); err != nil {
+				return nil, err
+			}
+		}
+		return manifests, nil
+	}
+
 	contentContainer := container.NewVBox(contentEntry)
 	contentContainer.Resize(fyne.NewSize(0, 890))
 
-	// Create a container for the button
+	perEntryCheck := widget.NewCheck("Sary iray isaky ny olona", nil)
+
+	textFormatSelect := widget.NewSelect([]string{"Soratra", "WhatsApp"}, nil)
+	textFormatSelect.SetSelected("Soratra")
+
+	// Switching paper only changes the page size and margins of the saved settings
+	paperSelect := widget.NewSelect(pdf.PaperNames, nil)
+	paperSelect.SetSelected("78mm")
+	if settings.Paper != "" {
+		paperSelect.SetSelected(settings.Paper)
+	}
+	paperSelect.OnChanged = func(selected string) {
+		if err := config.ApplyPaper(selected); err != nil {
+			dialog.ShowError(err, myWindow)
+			return
+		}
+		settings.Paper = selected
+	}
+
+	// Larger text for couriers who struggle with the small print
+	textScales := map[string]float64{"100%": 1.0, "125%": 1.25, "150%": 1.5}
+	scaleSelect := widget.NewSelect([]string{"100%", "125%", "150%"}, nil)
+	scaleSelect.SetSelected("100%")
+	for label, scale := range textScales {
+		if scale == config.Typography.Scale {
+			scaleSelect.SetSelected(label)
+		}
+	}
+	scaleSelect.OnChanged = func(selected string) {
+		config.Typography.Scale = textScales[selected]
+	}
+
+	// Sharper images weigh more to send over a slow connection
+	dpiLabels := []string{"150 DPI", "200 DPI", "300 DPI"}
+	if label := fmt.Sprintf("%v DPI", config.DPI); !slices.Contains(dpiLabels, label) {
+		dpiLabels = append(dpiLabels, label)
+	}
+	dpiSelect := widget.NewSelect(dpiLabels, nil)
+	dpiSelect.SetSelected(fmt.Sprintf("%v DPI", config.DPI))
+	dpiSelect.OnChanged = func(selected string) {
+		fmt.Sscanf(selected, "%g DPI", &config.DPI)
+	}
+
+	// Create a container for the buttons
 	buttonContainer := container.NewHBox(
 		layout.NewSpacer(),
 		widget.NewButton("Avoay fa maika e!", func() {
-			zone := zoneEntry.Text
-			content := contentEntry.Text
+			manifest, err := readManifest()
+			if err != nil {
+				dialog.ShowError(err, myWindow)
+				return
+			}
+
+			path, err := pdf.GeneratePDF(manifest, settings.ForZone(manifest.Zone))
+			if err != nil {
+				dialog.ShowError(err, myWindow)
+				return
+			}
+
+			showSaved(myWindow, path)
+		}),
+		widget.NewButton("Trasy maromaro", func() {
+			manifests, err := readBatch()
+			if err != nil {
+				dialog.ShowError(err, myWindow)
+				return
+			}
+
+			result, err := pdf.GenerateBatch(manifests, settings)
+			if err != nil {
+				dialog.ShowError(err, myWindow)
+				return
+			}
+
+			report := pdf.FormatBatchReport(manifests, &settings.Config.Labels)
+			dialog.ShowInformation("Poinsa", report+"\nTadiavo rery ao amzay:\n"+result.Bundle, myWindow)
+		}),
+		widget.NewButton("Marika ho an'ny entana", func() {
+			manifest, err := readManifest()
+			if err != nil {
+				dialog.ShowError(err, myWindow)
+				return
+			}
 
-			if zone == "" || content == "" {
+			path, err := pdf.GenerateLabels(manifest, settings.LabelSheetFor(manifest.Zone))
+			if err != nil {
+				dialog.ShowError(err, myWindow)
+				return
+			}
+
+			showSaved(myWindow, path)
+		}),
+		widget.NewButton("Sary PNG", func() {
+			manifest, err := readManifest()
+			if err != nil {
+				dialog.ShowError(err, myWindow)
+				return
+			}
+
+			paths, err := pdf.ExportPNG(manifest, settings.ForZone(manifest.Zone), perEntryCheck.Checked)
+			if err != nil {
+				dialog.ShowError(err, myWindow)
+				return
+			}
+
+			showSaved(myWindow, paths...)
+		}),
+		perEntryCheck,
+		dpiSelect,
+		widget.NewButton("Adikao ho soratra", func() {
+			manifest, err := readManifest()
+			if err != nil {
+				dialog.ShowError(err, myWindow)
+				return
+			}
+
+			textConfig := pdf.DefaultTextConfig()
+			if textFormatSelect.Selected == "WhatsApp" {
+				textConfig.Format = pdf.TextMarkdown
+			}
+
+			myApp.Clipboard().SetContent(pdf.FormatText(manifest, settings.ForZone(manifest.Zone), textConfig))
+
+			dialog.ShowInformation("Poinsa", "Voadika, apetaho fotsiny", myWindow)
+		}),
+		textFormatSelect,
+		widget.NewButton("HTML", func() {
+			manifest, err := readManifest()
+			if err != nil {
+				dialog.ShowError(err, myWindow)
+				return
+			}
+
+			path, err := pdf.ExportHTML(manifest, settings.ForZone(manifest.Zone), &pdf.HTMLConfig{Paper: paperSelect.Selected})
+			if err != nil {
+				dialog.ShowError(err, myWindow)
+				return
+			}
+
+			showSaved(myWindow, path)
+		}),
+		widget.NewButton("Printy mivantana", func() {
+			target := printerEntry.Text
+			if target == "" {
 				dialog.ShowError(fmt.Errorf("mba fenoy tsara pr aloha (par respect)"), myWindow)
 				return
 			}
 
-			entries := pdf.ParseContent(content)
-			err := pdf.GeneratePDF(zone, entries, pdf.DefaultConfig())
+			manifest, err := readManifest()
 			if err != nil {
 				dialog.ShowError(err, myWindow)
 				return
 			}
 
-			dialog.ShowInformation("Poinsa", "Tadiavo rery ao amzay", myWindow)
+			if err := pdf.PrintEscPos(manifest, settings.ForZone(manifest.Zone), pdf.DefaultEscPosConfig(), target); err != nil {
+				dialog.ShowError(err, myWindow)
+				return
+			}
+
+			dialog.ShowInformation("Poinsa", "Lasa any amin'ny printy", myWindow)
+		}),
+		widget.NewButton("Tehirizo ny fikirana", func() {
+			if err := pdf.SaveUserSettings(settings); err != nil {
+				dialog.ShowError(err, myWindow)
+				return
+			}
+
+			dialog.ShowInformation("Poinsa", "Voatahiry ny fikirana", myWindow)
 		}),
 		layout.NewSpacer(),
 	)
 
+	printerContainer := container.NewVBox(
+		widget.NewLabelWithStyle("Printy:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		printerEntry,
+		container.NewHBox(
+			widget.NewLabelWithStyle("Taratasy:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			paperSelect,
+			widget.NewLabelWithStyle("Haben'ny soratra:", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+			scaleSelect,
+		),
+	)
+
 	// Combine all containers
 	mainContainer := container.NewVBox(
 		formContainer,
 		contentContainer,
+		printerContainer,
 		buttonContainer,
 	)
 
@@ -77,5 +354,24 @@ func main() {
 	paddedContainer := container.NewPadded(mainContainer)
 
 	myWindow.SetContent(paddedContainer)
+	if templateErr != nil {
+		dialog.ShowError(templateErr, myWindow)
+	}
+	if settingsErr != nil {
+		dialog.ShowError(settingsErr, myWindow)
+	}
+	if len(settingsWarnings) > 0 {
+		dialog.ShowInformation("Fampitandremana", strings.Join(settingsWarnings, "\n"), myWindow)
+	}
 	myWindow.ShowAndRun()
 }
+
+// showSaved tells where the files were saved, listing the first few when there are many
+func showSaved(window fyne.Window, paths ...string) {
+	const shown = 5
+	message := "Tadiavo rery ao amzay:\n" + strings.Join(paths[:min(len(paths), shown)], "\n")
+	if len(paths) > shown {
+		message += fmt.Sprintf("\n(+%d hafa ao amin'ny %s)", len(paths)-shown, filepath.Dir(paths[0]))
+	}
+	dialog.ShowInformation("Poinsa", message, window)
+}
@@ -0,0 +1,186 @@
+package pdf
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// BatchResult lists the files saved for a batch of zones
+type BatchResult struct {
+	Files  []string // One PDF per zone, in the order of the zones
+	Report string   // Counts and totals per zone
+	Bundle string   // ZIP archive of the PDFs and the report
+}
+
+// GenerateBatch saves the PDF of every manifest with the settings of its zone, then a
+// report of the counts and totals per zone and a ZIP bundle of all of them
+func GenerateBatch(manifests []*Manifest, settings *Settings) (*BatchResult, error) {
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("no zone to generate")
+	}
+	if settings == nil {
+		settings = DefaultSettings()
+	}
+	for _, manifest := range manifests {
+		if manifest.Zone == "" {
+			return nil, fmt.Errorf("%d entries have no zone", len(manifest.Entries))
+		}
+	}
+
+	var bundle bytes.Buffer
+	archive := zip.NewWriter(&bundle)
+	archived := make(map[string]bool)
+	add := func(name string, data []byte) error {
+		// Zones saved to different directories may share a file name
+		base := name
+		for n := 2; archived[name]; n++ {
+			name = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(base, filepath.Ext(base)), n, filepath.Ext(base))
+		}
+		archived[name] = true
+
+		w, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	result := &BatchResult{}
+	for _, manifest := range manifests {
+		path, data, err := saveZone(manifest, settings)
+		if err != nil {
+			return result, err
+		}
+		result.Files = append(result.Files, path)
+
+		if err := add(filepath.Base(path), data); err != nil {
+			return result, fmt.Errorf("could not add %s to the bundle: %v", path, err)
+		}
+	}
+
+	// The report and the bundle cover every zone, they are named after the round alone
+	// and its first day when the zones are for different days
+	first, _ := batchDays(manifests)
+	round := &Manifest{Date: first, Courier: manifests[0].Courier}
+	for _, manifest := range manifests {
+		if manifest.Courier != round.Courier {
+			round.Courier = ""
+		}
+	}
+	report := []byte(FormatBatchReport(manifests, &settings.Config.Labels))
+	path, err := saveFile(settings.Config.Output, "tatitra", round, ".txt", report)
+	if err != nil {
+		return result, err
+	}
+	result.Report = path
+	if err := add(filepath.Base(path), report); err != nil {
+		return result, fmt.Errorf("could not add %s to the bundle: %v", path, err)
+	}
+
+	if err := archive.Close(); err != nil {
+		return result, fmt.Errorf("could not write the bundle: %v", err)
+	}
+	result.Bundle, err = saveFile(settings.Config.Output, "fanatitra", round, ".zip", bundle.Bytes())
+	return result, err
+}
+
+// saveZone saves the PDF of one zone of a batch with the settings of the zone, and
+// returns its path and content
+func saveZone(manifest *Manifest, settings *Settings) (string, []byte, error) {
+	config := settings.ForZone(manifest.Zone)
+	data, err := RenderPDF(context.Background(), manifest, config)
+	if err != nil {
+		return "", nil, fmt.Errorf("zone %q: %v", manifest.Zone, err)
+	}
+	path, err := saveFile(config.Output, "fanatitra", manifest, ".pdf", data)
+	if err != nil {
+		return "", nil, fmt.Errorf("zone %q: %v", manifest.Zone, err)
+	}
+	return path, data, nil
+}
+
+// batchDays returns the first and the last day of the zones of a batch
+func batchDays(manifests []*Manifest) (time.Time, time.Time) {
+	var first, last time.Time
+	for i, manifest := range manifests {
+		day := manifest.Day()
+		if i == 0 || day.Before(first) {
+			first = day
+		}
+		if i == 0 || day.After(last) {
+			last = day
+		}
+	}
+	return first, last
+}
+
+// FormatBatchReport lists the number of deliveries and the amount to collect in each
+// zone of a batch, with the totals of the round. The day of each zone is listed too
+// when the zones are for different days.
+func FormatBatchReport(manifests []*Manifest, labels *Labels) string {
+	first, last := batchDays(manifests)
+	days := first.Format("02/01/2006")
+	mixed := last.Format("02/01/2006") != days
+	if mixed {
+		days += " - " + last.Format("02/01/2006")
+	}
+
+	row := func(zone, day, entries, total string) []string {
+		if mixed {
+			return []string{zone, day, entries, total}
+		}
+		return []string{zone, entries, total}
+	}
+	rows := [][]string{row(labels.Zone, strings.TrimSuffix(labels.Date, ":"), labels.Count, labels.Amount)}
+	count, sum := 0, 0.0
+	for _, manifest := range manifests {
+		total := 0.0
+		for _, entry := range manifest.Entries {
+			total += entry.CalculateTotal()
+		}
+		rows = append(rows, row(manifest.Zone, manifest.Day().Format("02/01/2006"), fmt.Sprint(len(manifest.Entries)), FormatAmount(total, labels)))
+		count += len(manifest.Entries)
+		sum += total
+	}
+	rows = append(rows, row(labels.Total, "", fmt.Sprint(count), FormatAmount(sum, labels)))
+
+	widths := make([]int, len(rows[0]))
+	for _, r := range rows {
+		for i, cell := range r {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	lineWidth := 2 * (len(widths) - 1)
+	for _, width := range widths {
+		lineWidth += width
+	}
+
+	var sb strings.Builder
+	if len(manifests) > 0 {
+		fmt.Fprintf(&sb, "%s %s\n", labels.Date, days)
+	}
+	for i, r := range rows {
+		if i == len(rows)-1 {
+			sb.WriteString(strings.Repeat("-", lineWidth) + "\n")
+		}
+		// The zone is aligned left, the day and the numbers right
+		cells := make([]string, len(r))
+		for j, cell := range r {
+			pad := strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell))
+			if j == 0 {
+				cells[j] = cell + pad
+			} else {
+				cells[j] = pad + cell
+			}
+		}
+		sb.WriteString(strings.Join(cells, "  ") + "\n")
+	}
+	return sb.String()
+}
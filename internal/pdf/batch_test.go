@@ -0,0 +1,173 @@
+package pdf
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBatch(t *testing.T) {
+	content := strings.Join([]string{
+		"date: 19/10/2026",
+		"courier: Rado",
+		"000\tRasoa\tAnosy\t0321111111\t5\t",
+		"zone: Analakely",
+		"001\tRakoto\tLot II A 12\t0341234567\t12+3\t",
+		"zone: Ivato",
+		"courier: Hery",
+		"date: 20/10/2026",
+		"002\tRabe\tAéroport\t0331234567\t20\t",
+		"003\tRasoa\tBehoririka\t0321234567\t7\t\tAnalakely",
+		"004\tRanaivo\tAmbohibao\t0341111111\t9\t\tMahitsy",
+	}, "\n")
+
+	manifests, warnings, err := ParseBatch(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) > 0 {
+		t.Errorf("warnings %q", warnings)
+	}
+
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.Local) }
+	want := []struct {
+		zone    string
+		courier string
+		date    time.Time
+		ids     []string
+	}{
+		{"", "Rado", day(19), []string{"000"}},
+		{"Analakely", "Rado", day(19), []string{"001", "003"}},
+		{"Ivato", "Hery", day(20), []string{"002"}},
+		{"Mahitsy", "Rado", day(19), []string{"004"}},
+	}
+	if len(manifests) != len(want) {
+		t.Fatalf("%d manifests, want %d", len(manifests), len(want))
+	}
+	for i, manifest := range manifests {
+		var ids []string
+		for _, entry := range manifest.Entries {
+			ids = append(ids, entry.ID)
+		}
+		if manifest.Zone != want[i].zone || manifest.Courier != want[i].courier || !manifest.Date.Equal(want[i].date) || !slices.Equal(ids, want[i].ids) {
+			t.Errorf("manifest %d: zone %q, courier %q, date %v, entries %v; want %+v", i, manifest.Zone, manifest.Courier, manifest.Date, ids, want[i])
+		}
+	}
+
+	// A zone named again in any case goes on in the same manifest
+	manifests, _, err = ParseBatch("zone: Ivato\n001\tA\t\t\t1\t\nzone: IVATO\n002\tB\t\t\t1\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifests) != 1 || len(manifests[0].Entries) != 2 {
+		t.Errorf("zone named twice read as %d manifests", len(manifests))
+	}
+
+	if _, _, err := ParseBatch("zone: Ivato\ndate: 32/10/2026"); err == nil {
+		t.Error("an invalid date was accepted")
+	}
+}
+
+func TestGenerateBatch(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.Local) }
+	manifests := []*Manifest{
+		{Zone: "Analakely", Date: day(19), Courier: "Rado", Entries: []DeliveryEntry{{ID: "001", Name: "Rakoto", Items: "12+3"}}},
+		{Zone: "Ivato", Date: day(19), Courier: "Rado", Entries: []DeliveryEntry{{ID: "002", Name: "Rabe", Items: "20"}, {ID: "003", Name: "Rasoa", Items: "7"}}},
+		{Zone: "Analakely/Behoririka", Date: day(20), Courier: "Rado", Entries: []DeliveryEntry{{ID: "004", Name: "Ranaivo", Items: "9"}}},
+	}
+	settings := DefaultSettings()
+	settings.Config.Output = t.TempDir()
+	settings.Config.Labels.Zone = "Zone"
+	settings.Config.Labels.Total = "Total"
+
+	result, err := GenerateBatch(manifests, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := settings.Config.Output
+	wantFiles := []string{
+		filepath.Join(dir, "fanatitra_Analakely_2026-10-19.pdf"),
+		filepath.Join(dir, "fanatitra_Ivato_2026-10-19.pdf"),
+		filepath.Join(dir, "fanatitra_Analakely-Behoririka_2026-10-20.pdf"),
+	}
+	if !slices.Equal(result.Files, wantFiles) {
+		t.Errorf("files %q, want %q", result.Files, wantFiles)
+	}
+	for _, path := range result.Files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(data, []byte("%PDF")) {
+			t.Errorf("%s is not a PDF", path)
+		}
+	}
+
+	// The report and the bundle are named after the first day of the round
+	if want := filepath.Join(dir, "tatitra_2026-10-19.txt"); result.Report != want {
+		t.Errorf("report %s, want %s", result.Report, want)
+	}
+	if want := filepath.Join(dir, "fanatitra_2026-10-19.zip"); result.Bundle != want {
+		t.Errorf("bundle %s, want %s", result.Bundle, want)
+	}
+	report, err := os.ReadFile(result.Report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Daty: 19/10/2026 - 20/10/2026", "Zone ", "Ivato", "20/10/2026", "Total "} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("report has no %q:\n%s", want, report)
+		}
+	}
+
+	archive, err := zip.OpenReader(result.Bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	var names []string
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+	}
+	wantNames := []string{
+		"fanatitra_Analakely_2026-10-19.pdf",
+		"fanatitra_Ivato_2026-10-19.pdf",
+		"fanatitra_Analakely-Behoririka_2026-10-20.pdf",
+		"tatitra_2026-10-19.txt",
+	}
+	if !slices.Equal(names, wantNames) {
+		t.Errorf("bundle holds %q, want %q", names, wantNames)
+	}
+
+	if _, err := GenerateBatch([]*Manifest{{Entries: manifests[0].Entries}}, settings); err == nil {
+		t.Error("entries without a zone were accepted")
+	}
+}
+
+func TestFormatBatchReport(t *testing.T) {
+	labels := DefaultLabels()
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local)
+	manifests := []*Manifest{
+		{Zone: "Analakely", Date: day, Entries: []DeliveryEntry{{Items: "12+3"}}},
+		{Zone: "Ivato", Date: day, Entries: []DeliveryEntry{{Items: "20"}, {Items: "7"}}},
+	}
+
+	want := strings.Join([]string{
+		"Daty: 19/10/2026",
+		"Trasy      Isa      Vola",
+		"Analakely    1  15000 Ar",
+		"Ivato        2  27000 Ar",
+		"------------------------",
+		"Totaly       3  42000 Ar",
+		"",
+	}, "\n")
+	if got := FormatBatchReport(manifests, &labels); got != want {
+		t.Errorf("report\n%s\nwant\n%s", got, want)
+	}
+}
@@ -13,6 +13,7 @@ type DeliveryEntry struct {
 	Phone   string
 	Items   string
 	Notes   string
+	Zone    string // Zone of the entry when the input lists several, see ParseBatch
 }
 
 // ParseContent parses a tab-separated string into a slice of DeliveryEntry
@@ -50,6 +51,9 @@ func ParseContent(content string) []DeliveryEntry {
 			if len(fields) > 5 {
 				entry.Notes = fields[5]
 			}
+			if len(fields) > 6 {
+				entry.Zone = strings.TrimSpace(fields[6])
+			}
 			entries = append(entries, entry)
 		}
 	}
@@ -80,6 +84,32 @@ func parseFloat(s string) (float64, error) {
 	return f * 1000, err // Convert to Ariary
 }
 
+// FormatAmount formats an amount in Ariary followed by the currency label
+func FormatAmount(n float64, labels *Labels) string {
+	return fmt.Sprintf("%s %s", FormatNumber(n), labels.Currency)
+}
+
+// FormatItem formats an item price in thousands, or the gift label for free items.
+// It reports false when the price cannot be read.
+func FormatItem(price string, labels *Labels) (string, bool) {
+	p, err := parseFloat(strings.TrimSpace(price))
+	if err != nil {
+		return "", false
+	}
+	if p == 0 {
+		return labels.Gift, true
+	}
+	return fmt.Sprintf("%sk", FormatNumber(p/1000)), true
+}
+
+// FormatID formats the customer ID line, with a dash when the ID is missing
+func FormatID(id string, labels *Labels) string {
+	if id == "" {
+		return fmt.Sprintf("%s: -", labels.ID)
+	}
+	return fmt.Sprintf("%s: %s", labels.ID, id)
+}
+
 // FormatNumber formats a float64 as a string without decimal places
 func FormatNumber(n float64) string {
 	return fmt.Sprintf("%.0f", n)
@@ -0,0 +1,98 @@
+package pdf
+
+import (
+	"strings"
+	"unicode"
+)
+
+// textSpan is a part of a text drawn in a single font style
+type textSpan struct {
+	Text  string
+	Style FontStyle
+}
+
+// appendSpan adds text in a style to spans, extending the last span when it has the
+// same style
+func appendSpan(spans []textSpan, text string, style FontStyle) []textSpan {
+	if n := len(spans); n > 0 && spans[n-1].Style == style {
+		spans[n-1].Text += text
+		return spans
+	}
+	return append(spans, textSpan{Text: text, Style: style})
+}
+
+// parseEmphasis splits text marked up as in chat apps, *bold* and _italic_, into spans
+// of the base style with the emphasis added. Markers that are not closed, or that do
+// not hug the words they enclose as in "5 * 2" or "snake_case", are kept as text.
+func parseEmphasis(text string, base FontStyle) []textSpan {
+	runes := []rune(text)
+	var spans []textSpan
+	var current strings.Builder
+	bold, italic := base.Bold(), base.Italic()
+	open := map[rune]bool{}
+
+	flush := func() {
+		if current.Len() > 0 {
+			spans = appendSpan(spans, current.String(), fontStyle(bold, italic))
+			current.Reset()
+		}
+	}
+
+	for i, r := range runes {
+		if r == '*' || r == '_' {
+			toggle := false
+			if open[r] {
+				toggle = closesEmphasis(runes, i)
+			} else {
+				toggle = opensEmphasis(runes, i)
+			}
+			if toggle {
+				flush()
+				open[r] = !open[r]
+				if r == '*' {
+					bold = open[r] || base.Bold()
+				} else {
+					italic = open[r] || base.Italic()
+				}
+				continue
+			}
+		}
+		current.WriteRune(r)
+	}
+	flush()
+	return spans
+}
+
+// stripEmphasis returns text without its emphasis markers
+func stripEmphasis(text string) string {
+	var sb strings.Builder
+	for _, span := range parseEmphasis(text, FontRegular) {
+		sb.WriteString(span.Text)
+	}
+	return sb.String()
+}
+
+// opensEmphasis reports whether the marker at i starts an emphasis: it is followed by a
+// word, not preceded by one, and closed further on
+func opensEmphasis(runes []rune, i int) bool {
+	if i+1 >= len(runes) || unicode.IsSpace(runes[i+1]) || (i > 0 && isWordRune(runes[i-1])) {
+		return false
+	}
+	for j := i + 2; j < len(runes); j++ {
+		if runes[j] == runes[i] && closesEmphasis(runes, j) {
+			return true
+		}
+	}
+	return false
+}
+
+// closesEmphasis reports whether the marker at i can end an emphasis: it follows a word
+// and is not followed by one
+func closesEmphasis(runes []rune, i int) bool {
+	return i > 0 && !unicode.IsSpace(runes[i-1]) && (i+1 >= len(runes) || !isWordRune(runes[i+1]))
+}
+
+// isWordRune reports whether r is part of a word
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
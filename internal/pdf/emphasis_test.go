@@ -0,0 +1,95 @@
+package pdf
+
+import (
+	"slices"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestParseEmphasis(t *testing.T) {
+	for _, test := range []struct {
+		text string
+		base FontStyle
+		want []textSpan
+	}{
+		{"Miantso *alohan'ny* mandeha", FontRegular, []textSpan{{"Miantso ", FontRegular}, {"alohan'ny", FontBold}, {" mandeha", FontRegular}}},
+		{"*Rakoto* sy _Rabe_", FontRegular, []textSpan{{"Rakoto", FontBold}, {" sy ", FontRegular}, {"Rabe", FontItalic}}},
+		{"_Ambôhy_", FontRegular, []textSpan{{"Ambôhy", FontItalic}}},
+		{"*Tonga!*, tsara", FontRegular, []textSpan{{"Tonga!", FontBold}, {", tsara", FontRegular}}},
+
+		// Markers that do not hug a word are text
+		{"5 * 2 = 10", FontRegular, []textSpan{{"5 * 2 = 10", FontRegular}}},
+		{"5*2*3", FontRegular, []textSpan{{"5*2*3", FontRegular}}},
+		{"* tsy * izy", FontRegular, []textSpan{{"* tsy * izy", FontRegular}}},
+		{"snake_case_name", FontRegular, []textSpan{{"snake_case_name", FontRegular}}},
+		{"**", FontRegular, []textSpan{{"**", FontRegular}}},
+
+		// Markers that are not closed are text
+		{"*tsy vita", FontRegular, []textSpan{{"*tsy vita", FontRegular}}},
+		{"vita_", FontRegular, []textSpan{{"vita_", FontRegular}}},
+		{"*Rakoto* *Rabe", FontRegular, []textSpan{{"Rakoto", FontBold}, {" *Rabe", FontRegular}}},
+
+		// Nested markers add up
+		{"*_Rakoto_*", FontRegular, []textSpan{{"Rakoto", FontBoldItalic}}},
+		{"_*Rakoto*_", FontRegular, []textSpan{{"Rakoto", FontBoldItalic}}},
+		{"*Rakoto _Rabe_*", FontRegular, []textSpan{{"Rakoto ", FontBold}, {"Rabe", FontBoldItalic}}},
+
+		// Emphasis adds to the base style
+		{"Rakoto *vip*", FontBold, []textSpan{{"Rakoto vip", FontBold}}},
+		{"Rakoto *vip*", FontItalic, []textSpan{{"Rakoto ", FontItalic}, {"vip", FontBoldItalic}}},
+
+		{"", FontRegular, nil},
+	} {
+		if got := parseEmphasis(test.text, test.base); !slices.Equal(got, test.want) {
+			t.Errorf("parseEmphasis(%q, %v) = %v, want %v", test.text, test.base, got, test.want)
+		}
+	}
+
+	if got := stripEmphasis("Miantso *alohan'ny* _izy_, 5 * 2"); got != "Miantso alohan'ny izy, 5 * 2" {
+		t.Errorf("stripEmphasis = %q", got)
+	}
+}
+
+func TestWrapSpans(t *testing.T) {
+	// Bold text is twice as wide, so widths show which style each part is measured in
+	measure := func(text string, style FontStyle) float64 {
+		width := float64(utf8.RuneCountInString(text))
+		if style.Bold() {
+			width *= 2
+		}
+		return width
+	}
+
+	for _, test := range []struct {
+		spans     []textSpan
+		width     float64
+		hyphenate bool
+		want      [][]textSpan
+	}{
+		{parseEmphasis("Miantso *alohan'ny* mandeha", FontRegular), 30, false, [][]textSpan{
+			{{"Miantso ", FontRegular}, {"alohan'ny", FontBold}},
+			{{"mandeha", FontRegular}},
+		}},
+		{parseEmphasis("Miantso *alohan'ny* mandeha", FontRegular), 40, false, [][]textSpan{
+			{{"Miantso ", FontRegular}, {"alohan'ny ", FontBold}, {"mandeha", FontRegular}},
+		}},
+		// The parts of a word in different styles stay together when they fit, such as
+		// spans made by the layout rather than by markers
+		{[]textSpan{{"Ra", FontBold}, {"koto Rabe", FontRegular}}, 20, false, [][]textSpan{
+			{{"Ra", FontBold}, {"koto Rabe", FontRegular}},
+		}},
+		// A word too wide is split in its own style
+		{parseEmphasis("*Andrianampoinimerina* _Ivato_", FontRegular), 10, false, [][]textSpan{
+			{{"Andri", FontBold}}, {{"anamp", FontBold}}, {{"oinim", FontBold}}, {{"erina", FontBold}},
+			{{"Ivato", FontItalic}},
+		}},
+		{parseEmphasis("_Andrianampoinimerina_", FontRegular), 8, true, [][]textSpan{
+			{{"Andria-", FontItalic}}, {{"nampoi-", FontItalic}}, {{"nimerina", FontItalic}},
+		}},
+	} {
+		got := wrapSpans(measure, test.spans, test.width, test.hyphenate)
+		if !slices.EqualFunc(got, test.want, slices.Equal) {
+			t.Errorf("wrapSpans(%v, %v) = %v, want %v", test.spans, test.width, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,405 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/png"
+	"io"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// EscPosConfig describes the thermal printer raw ESC/POS output is sent to
+type EscPosConfig struct {
+	CharsPerLine      int     // Characters per line in the normal font
+	CharWidth         float64 // Width of one normal character in mm
+	CharHeight        float64 // Height of one normal character in mm
+	DotsPerMM         float64
+	LargeTextSize     float64 // Text at or above this size is printed double width and height
+	CutBetweenEntries bool
+	FeedBeforeCut     float64 // Paper fed in mm so the cut lands below the printed text
+}
+
+// DefaultEscPosConfig returns settings for an 80mm printer with the standard 12x24 dot font
+func DefaultEscPosConfig() *EscPosConfig {
+	return &EscPosConfig{
+		CharsPerLine:      48,
+		CharWidth:         1.5,
+		CharHeight:        3.0,
+		DotsPerMM:         8.0,
+		LargeTextSize:     12.0,
+		CutBetweenEntries: true,
+		FeedBeforeCut:     12.0,
+	}
+}
+
+// EscPosRenderer writes documents as raw ESC/POS commands and measures text in printer cells
+type EscPosRenderer struct {
+	config *EscPosConfig
+}
+
+// escPosRow is a printed line: either text runs sharing the same Y or a horizontal rule
+type escPosRow struct {
+	y      float64
+	runs   []TextRun
+	rule   bool
+	x1     float64
+	x2     float64
+	dashed bool
+}
+
+// NewEscPosRenderer creates an ESC/POS renderer, using the default printer settings when config is nil
+func NewEscPosRenderer(config *EscPosConfig) *EscPosRenderer {
+	if config == nil {
+		config = DefaultEscPosConfig()
+	}
+	return &EscPosRenderer{config: config}
+}
+
+// MeasureText implements Measurer
+func (r *EscPosRenderer) MeasureText(text string, style FontStyle, size float64) float64 {
+	return float64(utf8.RuneCountInString(text)) * r.config.CharWidth * float64(r.scale(size))
+}
+
+// HasGlyph implements GlyphChecker: the printer can print the characters of its code page
+func (r *EscPosRenderer) HasGlyph(ch rune, style FontStyle) bool {
+	_, ok := charmap.CodePage850.EncodeRune(ch)
+	return ok
+}
+
+// scale returns the character magnification used for a font size
+func (r *EscPosRenderer) scale(size float64) int {
+	if size >= r.config.LargeTextSize {
+		return 2
+	}
+	return 1
+}
+
+// Render implements Renderer
+func (r *EscPosRenderer) Render(doc *Document, w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("\x1b@")     // Initialize printer
+	buf.WriteString("\x1bt\x02") // Code page PC850 for accented characters
+
+	left := leftEdge(doc)
+	lastY := 0.0
+	lastHeight := 0.0
+	for i, block := range doc.Blocks {
+		for _, img := range block.Images {
+			if err := r.writeImage(&buf, img); err != nil {
+				return err
+			}
+			lastY = img.Y + img.H
+			lastHeight = 0
+		}
+
+		for _, row := range r.rows(block) {
+			r.feed(&buf, math.Max(row.y-lastY, lastHeight))
+			lastY = row.y
+			lastHeight = r.config.CharHeight
+
+			if row.rule {
+				r.writeRule(&buf, row, left)
+				continue
+			}
+			lastHeight = r.writeText(&buf, row.runs, left)
+		}
+
+		last := i == len(doc.Blocks)-1
+		nextIsEntry := !last && doc.Blocks[i+1].Kind == BlockEntry
+		if block.Kind == BlockEntry && nextIsEntry && r.config.CutBetweenEntries {
+			r.feed(&buf, lastHeight+r.config.FeedBeforeCut)
+			buf.WriteString("\x1dV\x01") // Partial cut
+			lastY = doc.Blocks[i+1].Top
+			lastHeight = 0
+		}
+	}
+
+	r.feed(&buf, lastHeight+r.config.FeedBeforeCut)
+	buf.WriteString("\x1dV\x01")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// rows turns the texts, lines and box edges of a block into printable lines sorted from top to bottom
+func (r *EscPosRenderer) rows(block Block) []escPosRow {
+	var rows []escPosRow
+
+	for _, run := range block.Texts {
+		found := false
+		for i := range rows {
+			if !rows[i].rule && math.Abs(rows[i].y-run.Y) < 0.01 {
+				rows[i].runs = append(rows[i].runs, run)
+				found = true
+				break
+			}
+		}
+		if !found {
+			rows = append(rows, escPosRow{y: run.Y, runs: []TextRun{run}})
+		}
+	}
+
+	for _, line := range block.Lines {
+		// Only horizontal strokes can be drawn with characters
+		if math.Abs(line.Y1-line.Y2) < 0.01 {
+			rows = append(rows, escPosRow{y: line.Y1, rule: true, x1: line.X1, x2: line.X2, dashed: line.Dashed})
+		}
+	}
+
+	for _, box := range block.Boxes {
+		if box.Filled {
+			continue
+		}
+		rows = append(rows,
+			escPosRow{y: box.Y, rule: true, x1: box.X, x2: box.X + box.W, dashed: box.Dashed},
+			escPosRow{y: box.Y + box.H, rule: true, x1: box.X, x2: box.X + box.W, dashed: box.Dashed},
+		)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].y < rows[j].y
+	})
+	return rows
+}
+
+// writeText writes the runs of one line and returns the height it takes on paper
+func (r *EscPosRenderer) writeText(buf *bytes.Buffer, runs []TextRun, left float64) float64 {
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].X < runs[j].X
+	})
+
+	// A lone centered run is handed to the printer's own alignment
+	if len(runs) == 1 && runs[0].Align == AlignCenter {
+		buf.WriteString("\x1ba\x01")
+		r.writeRun(buf, runs[0])
+		buf.WriteString("\x1ba\x00")
+		return r.config.CharHeight * float64(r.scale(runs[0].Size))
+	}
+
+	height := r.config.CharHeight
+	column := 0
+	for _, run := range runs {
+		target := int(math.Round((run.X - left) / r.config.CharWidth))
+		if target > column {
+			buf.WriteString(strings.Repeat(" ", target-column))
+			column = target
+		}
+		r.writeRun(buf, run)
+		column += utf8.RuneCountInString(run.Text) * r.scale(run.Size)
+		height = math.Max(height, r.config.CharHeight*float64(r.scale(run.Size)))
+	}
+	return height
+}
+
+// writeRun writes a single text run with its emphasis and size. Printers have no
+// italics, italic runs are underlined instead.
+func (r *EscPosRenderer) writeRun(buf *bytes.Buffer, run TextRun) {
+	bold := run.Style.Bold()
+	italic := run.Style.Italic()
+	large := r.scale(run.Size) > 1
+
+	if bold {
+		buf.WriteString("\x1bE\x01")
+	}
+	if italic {
+		buf.WriteString("\x1b-\x01")
+	}
+	if large {
+		buf.WriteString("\x1d!\x11") // Double width and height
+	}
+	buf.Write(encodePC850(run.Text))
+	if large {
+		buf.WriteString("\x1d!\x00")
+	}
+	if italic {
+		buf.WriteString("\x1b-\x00")
+	}
+	if bold {
+		buf.WriteString("\x1bE\x00")
+	}
+}
+
+// writeRule writes a horizontal rule made of box-drawing characters or dashes
+func (r *EscPosRenderer) writeRule(buf *bytes.Buffer, row escPosRow, left float64) {
+	start := int(math.Round((row.x1 - left) / r.config.CharWidth))
+	end := int(math.Round((row.x2 - left) / r.config.CharWidth))
+	if end > r.config.CharsPerLine {
+		end = r.config.CharsPerLine
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end <= start {
+		return
+	}
+
+	char := "─"
+	if row.dashed {
+		char = "-"
+	}
+	buf.WriteString(strings.Repeat(" ", start))
+	buf.Write(encodePC850(strings.Repeat(char, end-start)))
+}
+
+// feed prints the pending line and advances the paper by mm millimeters
+func (r *EscPosRenderer) feed(buf *bytes.Buffer, mm float64) {
+	dots := int(math.Round(mm * r.config.DotsPerMM))
+	if dots < 0 {
+		dots = 0
+	}
+	for {
+		n := dots
+		if n > 255 {
+			n = 255
+		}
+		buf.Write([]byte{0x1b, 'J', byte(n)})
+		dots -= n
+		if dots == 0 {
+			return
+		}
+	}
+}
+
+// writeImage prints a picture file as a centered black and white raster
+func (r *EscPosRenderer) writeImage(buf *bytes.Buffer, img Image) error {
+	file, err := os.Open(img.Path)
+	if err != nil {
+		return fmt.Errorf("could not open image %s: %v", img.Path, err)
+	}
+	defer file.Close()
+
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return fmt.Errorf("could not decode image %s: %v", img.Path, err)
+	}
+
+	width := int(img.W * r.config.DotsPerMM)
+	height := int(img.H * r.config.DotsPerMM)
+	if width <= 0 || height <= 0 {
+		return nil
+	}
+
+	bytesPerRow := (width + 7) / 8
+	bounds := src.Bounds()
+	data := make([]byte, bytesPerRow*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// Nearest neighbour scaling
+			sx := bounds.Min.X + x*bounds.Dx()/width
+			sy := bounds.Min.Y + y*bounds.Dy()/height
+			if isDark(src.At(sx, sy)) {
+				data[y*bytesPerRow+x/8] |= 0x80 >> uint(x%8)
+			}
+		}
+	}
+
+	buf.WriteString("\x1ba\x01")
+	buf.Write([]byte{0x1d, 'v', '0', 0, byte(bytesPerRow), byte(bytesPerRow >> 8), byte(height), byte(height >> 8)})
+	buf.Write(data)
+	buf.WriteString("\x1ba\x00")
+	return nil
+}
+
+// isDark reports whether a pixel, composited on white paper, should be printed
+func isDark(c color.Color) bool {
+	r, g, b, a := c.RGBA()
+	// Blend onto white using the alpha channel
+	white := 0xffff - a
+	luminance := (299*(r+white) + 587*(g+white) + 114*(b+white)) / 1000
+	return luminance < 0x8000
+}
+
+// leftEdge returns the leftmost position used by any element of the document
+func leftEdge(doc *Document) float64 {
+	left := doc.Width
+	for _, block := range doc.Blocks {
+		for _, run := range block.Texts {
+			left = math.Min(left, run.X)
+		}
+		for _, line := range block.Lines {
+			left = math.Min(left, math.Min(line.X1, line.X2))
+		}
+		for _, box := range block.Boxes {
+			left = math.Min(left, box.X)
+		}
+	}
+	return left
+}
+
+// encodePC850 converts text to the printer code page, replacing characters it lacks
+func encodePC850(text string) []byte {
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		if b, ok := charmap.CodePage850.EncodeRune(r); ok {
+			out = append(out, b)
+			continue
+		}
+		switch r {
+		case '•':
+			out = append(out, '*')
+		case '’', '‘':
+			out = append(out, '\'')
+		case '“', '”':
+			out = append(out, '"')
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+// OpenPrinter opens a printer target: "tcp://host[:port]" for network printers,
+// anything else is treated as a file or device path such as /dev/usb/lp0
+func OpenPrinter(target string) (io.WriteCloser, error) {
+	if address, ok := strings.CutPrefix(target, "tcp://"); ok {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			address = net.JoinHostPort(address, "9100")
+		}
+		conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to printer %s: %v", address, err)
+		}
+		return conn, nil
+	}
+
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open printer %s: %v", target, err)
+	}
+	return file, nil
+}
+
+// PrintEscPos lays out the manifest for a thermal printer and sends it to target
+func PrintEscPos(manifest *Manifest, config *PDFConfig, escpos *EscPosConfig, target string) error {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("invalid PDF config: %v", err)
+	}
+
+	renderer := NewEscPosRenderer(escpos)
+	doc := BuildLayout(manifest, config, renderer)
+
+	out, err := OpenPrinter(target)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := renderer.Render(doc, out); err != nil {
+		return fmt.Errorf("could not print to %s: %v", target, err)
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,126 @@
+package pdf
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrintEscPos(t *testing.T) {
+	// A fake network printer keeping what it receives
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	manifest := &Manifest{
+		Zone: "Analakely",
+		Entries: []DeliveryEntry{
+			{ID: "001", Name: "Rakoto", Address: "Ambôhijanahary", Items: "12+3", Notes: "Miantso _aloha_"},
+			{ID: "002", Name: "Rabe", Items: "20"},
+			{ID: "003", Name: "Rasoa", Items: "7"},
+		},
+	}
+	if err := PrintEscPos(manifest, nil, nil, "tcp://"+listener.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	data := <-received
+
+	if !bytes.HasPrefix(data, []byte("\x1b@\x1bt\x02")) {
+		t.Errorf("output starts with %q, want ESC @ then code page PC850", data[:min(len(data), 8)])
+	}
+	for _, want := range []struct {
+		name string
+		data string
+	}{
+		{"bold on", "\x1bE\x01"},
+		{"bold off", "\x1bE\x00"},
+		{"underline on", "\x1b-\x01"},
+		{"underline off", "\x1b-\x00"},
+		{"ô in PC850", "Amb\x93hijanahary"},
+		{"underlined italics", "\x1b-\x01aloha\x1b-\x00"},
+	} {
+		if !bytes.Contains(data, []byte(want.data)) {
+			t.Errorf("no %s (%q) in the output", want.name, want.data)
+		}
+	}
+
+	// A partial cut between the entries and one at the end
+	if cuts := bytes.Count(data, []byte("\x1dV\x01")); cuts != len(manifest.Entries) {
+		t.Errorf("%d cuts, want %d", cuts, len(manifest.Entries))
+	}
+	if !bytes.HasSuffix(data, []byte("\x1dV\x01")) {
+		t.Error("the output does not end with a cut")
+	}
+	if first, name := bytes.Index(data, []byte("\x1dV\x01")), bytes.Index(data, []byte("Rabe")); first < 0 || first > name {
+		t.Error("no cut before the second entry")
+	}
+
+	listener.Close()
+	if err := PrintEscPos(manifest, nil, nil, "tcp://"+listener.Addr().String()); err == nil {
+		t.Error("printing to a printer that is gone did not fail")
+	}
+}
+
+func TestEscPosImage(t *testing.T) {
+	// A 16x4 picture, left half black
+	picture := image.NewGray(image.Rect(0, 0, 16, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 16; x++ {
+			if x < 8 {
+				picture.SetGray(x, y, color.Gray{})
+			} else {
+				picture.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	path := filepath.Join(t.TempDir(), "logo.png")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, picture); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	// 20x0.5mm at 8 dots per mm is 160x4 dots, 20 bytes per row
+	doc := &Document{Blocks: []Block{{Images: []Image{{Path: path, W: 20, H: 0.5}}}}}
+	var buf bytes.Buffer
+	if err := NewEscPosRenderer(nil).Render(doc, &buf); err != nil {
+		t.Fatal(err)
+	}
+	header := []byte{0x1d, 'v', '0', 0, 20, 0, 4, 0}
+	at := bytes.Index(buf.Bytes(), header)
+	if at < 0 {
+		t.Fatalf("no GS v 0 raster header %q in %q", header, buf.Bytes())
+	}
+	row := buf.Bytes()[at+len(header) : at+len(header)+20]
+	want := append(bytes.Repeat([]byte{0xff}, 10), make([]byte, 10)...)
+	if !bytes.Equal(row, want) {
+		t.Errorf("first raster row %x, want %x", row, want)
+	}
+
+	doc.Blocks[0].Images[0].Path = filepath.Join(t.TempDir(), "missing.png")
+	if err := NewEscPosRenderer(nil).Render(doc, io.Discard); err == nil {
+		t.Error("a missing image was not reported")
+	}
+}
@@ -1,209 +1,332 @@
 package pdf
 
 import (
+	"bytes"
+	"embed"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
-	"path/filepath"
-	"runtime"
+	"path"
+	"slices"
+	"unicode/utf8"
+
+	"golang.org/x/image/font/sfnt"
 )
 
-// FontPaths contains paths to regular and bold font files
+//go:embed fonts/*.ttf
+var embeddedFonts embed.FS
+
+// embeddedFontFiles are the files of the bundled Noto Sans family for each style
+var embeddedFontFiles = map[FontStyle]string{
+	FontRegular:    "fonts/NotoSans-Regular.ttf",
+	FontBold:       "fonts/NotoSans-Bold.ttf",
+	FontItalic:     "fonts/NotoSans-Italic.ttf",
+	FontBoldItalic: "fonts/NotoSans-BoldItalic.ttf",
+}
+
+// FontConfig selects the fonts text is drawn with
+type FontConfig struct {
+	System bool   `toml:"system"` // Draw with the system fonts found by FindFont instead of the bundled Noto Sans
+	Family string `toml:"family"` // System font family tried first, such as "DejaVu Sans"
+	File   string `toml:"file"`   // Font file or collection (.ttc) to draw with, its faces picked by style
+}
+
+// FontPaths contains the faces of a font family, and the faces tried in order for
+// characters they do not cover. Italic and BoldItalic have an empty Path when the
+// family has none, the upright faces are then slanted instead.
 type FontPaths struct {
-	Regular string
-	Bold    string
-}
-
-// FindFont searches for suitable fonts in common locations
-func FindFont() (*FontPaths, error) {
-	// Add OS-specific font paths
-	switch runtime.GOOS {
-	case "windows":
-		// Check for Arial
-		if _, err := os.Stat(`C:\Windows\Fonts\Arial.ttf`); err == nil {
-			if _, err := os.Stat(`C:\Windows\Fonts\Arialbd.ttf`); err == nil {
-				return &FontPaths{
-					Regular: `C:\Windows\Fonts\Arial.ttf`,
-					Bold:    `C:\Windows\Fonts\Arialbd.ttf`,
-				}, nil
-			}
-		}
+	Regular    FontFace
+	Bold       FontFace
+	Italic     FontFace
+	BoldItalic FontFace
+	Fallbacks  []FontFace
+}
 
-		// Check for Calibri
-		if _, err := os.Stat(`C:\Windows\Fonts\Calibri.ttf`); err == nil {
-			if _, err := os.Stat(`C:\Windows\Fonts\Calibrib.ttf`); err == nil {
-				return &FontPaths{
-					Regular: `C:\Windows\Fonts\Calibri.ttf`,
-					Bold:    `C:\Windows\Fonts\Calibrib.ttf`,
-				}, nil
-			}
-		}
+// preferredFamilies are the system font families tried, in order, when the config
+// names none or it is not installed
+var preferredFamilies = []string{"DejaVu Sans", "Liberation Sans", "Noto Sans", "Arial", "Helvetica", "Segoe UI", "Calibri"}
 
-		// Check for Segoe UI
-		if _, err := os.Stat(`C:\Windows\Fonts\Segoeui.ttf`); err == nil {
-			if _, err := os.Stat(`C:\Windows\Fonts\Segoeuib.ttf`); err == nil {
-				return &FontPaths{
-					Regular: `C:\Windows\Fonts\Segoeui.ttf`,
-					Bold:    `C:\Windows\Fonts\Segoeuib.ttf`,
-				}, nil
-			}
-		}
+// fallbackFamilies are families with a wide coverage of symbols and scripts, tried after
+// the regular or bold font
+var fallbackFamilies = []string{"DejaVu Sans", "Noto Sans Symbols 2", "Noto Emoji", "Symbola", "Segoe UI Symbol", "Segoe UI Emoji", "Arial Unicode MS"}
 
-	case "linux":
-		// Check for DejaVu Sans
-		if _, err := os.Stat("/usr/share/fonts/TTF/DejaVuSans.ttf"); err == nil {
-			if _, err := os.Stat("/usr/share/fonts/TTF/DejaVuSans-Bold.ttf"); err == nil {
-				return &FontPaths{
-					Regular: "/usr/share/fonts/TTF/DejaVuSans.ttf",
-					Bold:    "/usr/share/fonts/TTF/DejaVuSans-Bold.ttf",
-				}, nil
-			}
-		}
+// FindFont returns the faces of the first family among the preferred one and
+// preferredFamilies with a regular and a bold face installed, with the fallback fonts
+// that are installed
+func FindFont(preferred string) (*FontPaths, error) {
+	faces := ScanFonts()
+	families := preferredFamilies
+	if preferred != "" {
+		families = append([]string{preferred}, preferredFamilies...)
+	}
 
-		// Check for Liberation Sans
-		if _, err := os.Stat("/usr/share/fonts/truetype/liberation/LiberationSans-Regular.ttf"); err == nil {
-			if _, err := os.Stat("/usr/share/fonts/truetype/liberation/LiberationSans-Bold.ttf"); err == nil {
-				return &FontPaths{
-					Regular: "/usr/share/fonts/truetype/liberation/LiberationSans-Regular.ttf",
-					Bold:    "/usr/share/fonts/truetype/liberation/LiberationSans-Bold.ttf",
-				}, nil
-			}
+	for _, family := range families {
+		regular, ok := findFace(faces, family, FontRegular)
+		if !ok {
+			continue
 		}
-
-		// Check for Noto Sans
-		if _, err := os.Stat("/usr/share/fonts/noto/NotoSans-Regular.ttf"); err == nil {
-			if _, err := os.Stat("/usr/share/fonts/noto/NotoSans-Bold.ttf"); err == nil {
-				return &FontPaths{
-					Regular: "/usr/share/fonts/noto/NotoSans-Regular.ttf",
-					Bold:    "/usr/share/fonts/noto/NotoSans-Bold.ttf",
-				}, nil
-			}
+		bold, ok := findFace(faces, family, FontBold)
+		if !ok {
+			continue
 		}
 
-	case "darwin":
-		// Check for Arial
-		if _, err := os.Stat("/Library/Fonts/Arial.ttf"); err == nil {
-			if _, err := os.Stat("/Library/Fonts/Arial Bold.ttf"); err == nil {
-				return &FontPaths{
-					Regular: "/Library/Fonts/Arial.ttf",
-					Bold:    "/Library/Fonts/Arial Bold.ttf",
-				}, nil
-			}
+		italic, _ := findFace(faces, family, FontItalic)
+		boldItalic, _ := findFace(faces, family, FontBoldItalic)
+		return &FontPaths{
+			Regular:    regular,
+			Bold:       bold,
+			Italic:     italic,
+			BoldItalic: boldItalic,
+			Fallbacks:  findFallbackFonts(faces, regular, bold, italic, boldItalic),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no regular and bold system font found")
+}
+
+// FileFont returns the faces of a font file or collection, with the installed fallback
+// fonts. A file with a single face, or without a bold one, uses its first face for the
+// regular and bold styles it lacks.
+func FileFont(path string) (*FontPaths, error) {
+	faces := ReadFontFaces(path)
+	if len(faces) == 0 {
+		return nil, fmt.Errorf("could not read font %s", path)
+	}
+
+	styles := make(map[FontStyle]FontFace)
+	for _, face := range faces {
+		if style, ok := face.Style(); ok && styles[style].Path == "" {
+			styles[style] = face
 		}
+	}
+	paths := &FontPaths{Regular: faces[0], Bold: faces[0], Italic: styles[FontItalic], BoldItalic: styles[FontBoldItalic]}
+	if face, ok := styles[FontRegular]; ok {
+		paths.Regular = face
+	}
+	if face, ok := styles[FontBold]; ok {
+		paths.Bold = face
+	}
+	paths.Fallbacks = findFallbackFonts(ScanFonts(), paths.Regular, paths.Bold, paths.Italic, paths.BoldItalic)
+	return paths, nil
+}
 
-		// Check for Helvetica
-		if _, err := os.Stat("/System/Library/Fonts/Helvetica.ttc"); err == nil {
-			// Helvetica.ttc is a collection, we'll use it for both regular and bold
-			return &FontPaths{
-				Regular: "/System/Library/Fonts/Helvetica.ttc",
-				Bold:    "/System/Library/Fonts/Helvetica.ttc",
-			}, nil
+// findFallbackFonts returns the regular faces of the installed fallback families,
+// leaving out the excluded faces
+func findFallbackFonts(faces []FontFace, exclude ...FontFace) []FontFace {
+	var found []FontFace
+	for _, family := range fallbackFamilies {
+		if face, ok := findFace(faces, family, FontRegular); ok && !slices.Contains(exclude, face) {
+			found = append(found, face)
 		}
 	}
+	return found
+}
 
-	// If no system fonts found, try to use fallback fonts
-	return SetupFallbackFonts()
+// chainFont is one font of a chain, parsed to check which characters it covers
+type chainFont struct {
+	Name string
+	Data []byte
+	Font *sfnt.Font
 }
 
-// SetupFallbackFonts downloads Liberation Sans fonts if needed
-func SetupFallbackFonts() (*FontPaths, error) {
-	// Get user's home directory
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return nil, fmt.Errorf("could not get home directory: %v", err)
-	}
+// FontChain lists for each style the fonts text is drawn with, in order of preference:
+// each character uses the first font that has a glyph for it
+type FontChain struct {
+	fonts map[FontStyle][]*chainFont
+}
 
-	// Create fonts directory in user's home directory
-	fontsDir := filepath.Join(homeDir, ".fonts")
-	if err := os.MkdirAll(fontsDir, 0755); err != nil {
-		return nil, fmt.Errorf("could not create fonts directory: %v", err)
-	}
+// fontRun is a part of a text drawn with a single font of a chain
+type fontRun struct {
+	font int
+	text string
+}
 
-	// Use Liberation Sans as embedded fallback
-	regularFontPath := filepath.Join(fontsDir, "LiberationSans-Regular.ttf")
-	boldFontPath := filepath.Join(fontsDir, "LiberationSans-Bold.ttf")
+// LoadFonts returns the font chain selected by config: the font file it names, the
+// system fonts when asked for and found, or the bundled Noto Sans family, followed by
+// the installed fallback fonts
+func LoadFonts(config FontConfig) (*FontChain, error) {
+	chain, _, err := loadFonts(config)
+	return chain, err
+}
 
-	if _, err := os.Stat(regularFontPath); err != nil {
-		// Download Liberation Sans if not present
-		if err := downloadLiberationSans(regularFontPath); err != nil {
-			return nil, fmt.Errorf("could not setup fallback font: %v", err)
+// loadFonts returns the font chain selected by config, with the reason it was chosen
+func loadFonts(config FontConfig) (*FontChain, string, error) {
+	if config.File != "" {
+		paths, err := FileFont(config.File)
+		if err != nil {
+			return nil, "", err
 		}
+		chain, err := NewFontChain(paths)
+		return chain, "font file " + config.File + " from the settings", err
 	}
 
-	if _, err := os.Stat(boldFontPath); err != nil {
-		// Download Liberation Sans Bold if not present
-		if err := downloadLiberationSansBold(boldFontPath); err != nil {
-			return nil, fmt.Errorf("could not setup fallback bold font: %v", err)
+	reason := "bundled Noto Sans, system fonts are not enabled in the settings"
+	if config.System {
+		paths, err := FindFont(config.Family)
+		if err == nil {
+			chain, err := NewFontChain(paths)
+			return chain, "system family " + paths.Regular.Family, err
 		}
+		reason = fmt.Sprintf("bundled Noto Sans, %v", err)
 	}
 
-	return &FontPaths{
-		Regular: regularFontPath,
-		Bold:    boldFontPath,
-	}, nil
+	chain, err := EmbeddedFontChain(findFallbackFonts(ScanFonts()))
+	return chain, reason, err
 }
 
-// downloadLiberationSans downloads the Liberation Sans Regular font
-func downloadLiberationSans(destPath string) error {
-	// URL for Liberation Sans Regular
-	fontURL := "https://github.com/liberationfonts/liberation-fonts/raw/main/liberation-fonts-ttf-2.1.5/LiberationSans-Regular.ttf"
+// EmbeddedFontChain returns the bundled Noto Sans family, in all four styles, followed
+// by the fallback fonts
+func EmbeddedFontChain(fallbacks []FontFace) (*FontChain, error) {
+	primary := make(map[FontStyle]*chainFont)
+	for style, name := range embeddedFontFiles {
+		font, err := sharedFonts.embeddedFont(name)
+		if err != nil {
+			return nil, err
+		}
+		primary[style] = font
+	}
+	return newFontChain(primary, fallbacks), nil
+}
 
-	// Download the font file
-	resp, err := http.Get(fontURL)
+// loadEmbeddedFont reads and parses a bundled font
+func loadEmbeddedFont(name string) (*chainFont, error) {
+	data, err := embeddedFonts.ReadFile(name)
 	if err != nil {
-		return fmt.Errorf("failed to download font: %v", err)
+		return nil, fmt.Errorf("could not read bundled font %s: %v", name, err)
 	}
-	defer resp.Body.Close()
+	return parseChainFont("bundled "+path.Base(name), data)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download font: HTTP status %d", resp.StatusCode)
+// NewFontChain loads the fonts of paths, each followed by the fallbacks. Missing italic
+// faces are synthesized by slanting the regular and bold ones.
+func NewFontChain(paths *FontPaths) (*FontChain, error) {
+	primary := make(map[FontStyle]*chainFont)
+	for _, want := range []struct {
+		style   FontStyle
+		face    FontFace
+		upright FontStyle
+	}{
+		{FontRegular, paths.Regular, FontRegular},
+		{FontBold, paths.Bold, FontBold},
+		{FontItalic, paths.Italic, FontRegular},
+		{FontBoldItalic, paths.BoldItalic, FontBold},
+	} {
+		if want.face.Path != "" || want.style == want.upright {
+			font, err := readChainFont(want.face)
+			if err != nil {
+				return nil, err
+			}
+			if font.postScript() {
+				return nil, fmt.Errorf("font %s has PostScript outlines, only TrueType fonts can be embedded", font.Name)
+			}
+			primary[want.style] = font
+			continue
+		}
+
+		font, err := sharedFonts.oblique(primary[want.upright])
+		if err != nil {
+			return nil, err
+		}
+		primary[want.style] = font
 	}
 
-	// Create the destination file
-	out, err := os.Create(destPath)
+	return newFontChain(primary, paths.Fallbacks), nil
+}
+
+// obliqueChainFont returns a slanted copy of an upright font
+func obliqueChainFont(upright *chainFont) (*chainFont, error) {
+	data, err := obliqueFont(upright.Data)
 	if err != nil {
-		return fmt.Errorf("failed to create font file: %v", err)
+		return nil, fmt.Errorf("could not slant font %s: %v", upright.Name, err)
 	}
-	defer out.Close()
+	return parseChainFont(upright.Name+" (oblique)", data)
+}
 
-	// Copy the content
-	_, err = io.Copy(out, resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to save font file: %v", err)
+// newFontChain builds the chains of the primary fonts followed by the fallback fonts.
+// A broken fallback only costs the characters it would have covered, and fonts with
+// PostScript outlines are skipped as gopdf can only embed TrueType ones.
+func newFontChain(primary map[FontStyle]*chainFont, fallbacks []FontFace) *FontChain {
+	var loaded []*chainFont
+	for _, face := range fallbacks {
+		if font, err := readChainFont(face); err == nil && !font.postScript() {
+			loaded = append(loaded, font)
+		}
 	}
 
-	return nil
+	chain := &FontChain{fonts: make(map[FontStyle][]*chainFont)}
+	for style, font := range primary {
+		chain.fonts[style] = append([]*chainFont{font}, loaded...)
+	}
+	return chain
 }
 
-// downloadLiberationSansBold downloads the Liberation Sans Bold font
-func downloadLiberationSansBold(destPath string) error {
-	// URL for Liberation Sans Bold
-	fontURL := "https://github.com/liberationfonts/liberation-fonts/raw/main/liberation-fonts-ttf-2.1.5/LiberationSans-Bold.ttf"
+// readChainFont returns a parsed font face, read from its file only when it is new or
+// has changed since it was last read
+func readChainFont(face FontFace) (*chainFont, error) {
+	return sharedFonts.font(face)
+}
 
-	// Download the font file
-	resp, err := http.Get(fontURL)
+// loadChainFont reads and parses a font face, extracting it from its file when that is
+// a collection
+func loadChainFont(face FontFace) (*chainFont, error) {
+	data, err := os.ReadFile(face.Path)
 	if err != nil {
-		return fmt.Errorf("failed to download font: %v", err)
+		return nil, fmt.Errorf("could not read font %s: %v", face.Path, err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download font: HTTP status %d", resp.StatusCode)
+	if !isCollection(data) {
+		return parseChainFont(face.Path, data)
 	}
 
-	// Create the destination file
-	out, err := os.Create(destPath)
+	name := fmt.Sprintf("%s#%d", face.Path, face.Index)
+	data, err = extractCollectionFont(data, face.Index)
 	if err != nil {
-		return fmt.Errorf("failed to create font file: %v", err)
+		return nil, fmt.Errorf("could not read font %s: %v", name, err)
 	}
-	defer out.Close()
+	return parseChainFont(name, data)
+}
 
-	// Copy the content
-	_, err = io.Copy(out, resp.Body)
+// parseChainFont parses the content of a font
+func parseChainFont(name string, data []byte) (*chainFont, error) {
+	parsed, err := sfnt.Parse(data)
 	if err != nil {
-		return fmt.Errorf("failed to save font file: %v", err)
+		return nil, fmt.Errorf("could not parse font %s: %v", name, err)
+	}
+	return &chainFont{Name: name, Data: data, Font: parsed}, nil
+}
+
+// postScript reports whether the font has PostScript (CFF) outlines
+func (f *chainFont) postScript() bool {
+	return bytes.HasPrefix(f.Data, []byte("OTTO"))
+}
+
+// fontFor returns the index of the first font of the style that covers r, and false
+// when none does
+func (c *FontChain) fontFor(r rune, style FontStyle) (int, bool) {
+	for i, font := range c.fonts[style] {
+		if index, err := font.Font.GlyphIndex(nil, r); err == nil && index != 0 {
+			return i, true
+		}
 	}
+	return 0, false
+}
 
-	return nil
+// HasGlyph reports whether a font of the style's chain covers r
+func (c *FontChain) HasGlyph(r rune, style FontStyle) bool {
+	_, ok := c.fontFor(r, style)
+	return ok
+}
+
+// runs splits text into parts drawn with the same font, keeping each grapheme cluster
+// in the font of its first character
+func (c *FontChain) runs(text string, style FontStyle) []fontRun {
+	var runs []fontRun
+	for _, cluster := range graphemes(text) {
+		r, _ := utf8.DecodeRuneInString(cluster)
+		font, _ := c.fontFor(r, style)
+		if len(runs) > 0 && runs[len(runs)-1].font == font {
+			runs[len(runs)-1].text += cluster
+			continue
+		}
+		runs = append(runs, fontRun{font: font, text: cluster})
+	}
+	return runs
 }
@@ -0,0 +1,135 @@
+package pdf
+
+import (
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileStamp identifies the content of a file by its size and modification time, the
+// file is read again when either changes
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// stampOf returns the stamp of a file's current content
+func stampOf(info fs.FileInfo) fileStamp {
+	return fileStamp{size: info.Size(), modTime: info.ModTime()}
+}
+
+// cachedFaces are the names read from a font file
+type cachedFaces struct {
+	stamp fileStamp
+	faces []FontFace
+}
+
+// cachedFont is a font face read and parsed from a file
+type cachedFont struct {
+	stamp fileStamp
+	font  *chainFont
+}
+
+// fontCache keeps the fonts read during the life of the process, so generating many
+// manifests scans, reads, extracts and parses each font file once. gopdf still parses
+// the fonts each PDF embeds, as it only takes them as bytes.
+type fontCache struct {
+	mu       sync.Mutex
+	faces    map[string]cachedFaces
+	fonts    map[FontFace]*cachedFont
+	embedded map[string]*chainFont
+	obliques map[*chainFont]*chainFont
+}
+
+// sharedFonts is the font cache of the process
+var sharedFonts = &fontCache{
+	faces:    make(map[string]cachedFaces),
+	fonts:    make(map[FontFace]*cachedFont),
+	embedded: make(map[string]*chainFont),
+	obliques: make(map[*chainFont]*chainFont),
+}
+
+// fileFaces returns the faces of a font file, reading their names only when the file
+// is new or has changed since the last call
+func (c *fontCache) fileFaces(path string, info fs.FileInfo) []FontFace {
+	c.mu.Lock()
+	cached, ok := c.faces[path]
+	c.mu.Unlock()
+	if ok && cached.stamp == stampOf(info) {
+		return cached.faces
+	}
+
+	faces := ReadFontFaces(path)
+	c.mu.Lock()
+	c.faces[path] = cachedFaces{stamp: stampOf(info), faces: faces}
+	c.mu.Unlock()
+	return faces
+}
+
+// font returns a face read and parsed from its file, reading the file again only when
+// it has changed since the last call
+func (c *fontCache) font(face FontFace) (*chainFont, error) {
+	info, err := os.Stat(face.Path)
+	if err != nil {
+		return loadChainFont(face)
+	}
+
+	c.mu.Lock()
+	cached, ok := c.fonts[face]
+	c.mu.Unlock()
+	if ok && cached.stamp == stampOf(info) {
+		return cached.font, nil
+	}
+
+	font, err := loadChainFont(face)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if ok {
+		delete(c.obliques, cached.font)
+	}
+	c.fonts[face] = &cachedFont{stamp: stampOf(info), font: font}
+	c.mu.Unlock()
+	return font, nil
+}
+
+// embeddedFont returns a bundled font, parsed on first use
+func (c *fontCache) embeddedFont(name string) (*chainFont, error) {
+	c.mu.Lock()
+	font, ok := c.embedded[name]
+	c.mu.Unlock()
+	if ok {
+		return font, nil
+	}
+
+	font, err := loadEmbeddedFont(name)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.embedded[name] = font
+	c.mu.Unlock()
+	return font, nil
+}
+
+// oblique returns the slanted copy of an upright font, synthesized on first use. Fonts
+// read again after a change are new values, so their copies are made again too.
+func (c *fontCache) oblique(upright *chainFont) (*chainFont, error) {
+	c.mu.Lock()
+	font, ok := c.obliques[upright]
+	c.mu.Unlock()
+	if ok {
+		return font, nil
+	}
+
+	font, err := obliqueChainFont(upright)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.obliques[upright] = font
+	c.mu.Unlock()
+	return font, nil
+}
@@ -0,0 +1,175 @@
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// resetFontCache empties the font cache, as when the app has just started
+func resetFontCache() {
+	sharedFonts = &fontCache{
+		faces:    make(map[string]cachedFaces),
+		fonts:    make(map[FontFace]*cachedFont),
+		embedded: make(map[string]*chainFont),
+		obliques: make(map[*chainFont]*chainFont),
+	}
+}
+
+// benchManifests returns the lists of a morning round: zones of a few dozen deliveries
+func benchManifests(zones, entries int) []*Manifest {
+	var manifests []*Manifest
+	for z := 0; z < zones; z++ {
+		manifest := &Manifest{Zone: fmt.Sprintf("Faritra %d", z+1), Courier: "Rado"}
+		for e := 0; e < entries; e++ {
+			manifest.Entries = append(manifest.Entries, DeliveryEntry{
+				ID:      fmt.Sprintf("%03d", e+1),
+				Name:    "Randrianantenaina Hôtely",
+				Address: "Lot II M 45 bis/Ambohijanahary, akaikin'ny fiangonana",
+				Phone:   "034 12 345 67",
+				Items:   "12+3+25",
+				Notes:   "Miantso *alohan'ny* mandeha, _tsy misy_ vola madinika",
+			})
+		}
+		manifests = append(manifests, manifest)
+	}
+	return manifests
+}
+
+// benchFonts are the font settings benchmarked: the bundled fonts and the system ones
+var benchFonts = []struct {
+	name  string
+	fonts FontConfig
+}{
+	{"bundled", FontConfig{}},
+	{"system", FontConfig{System: true}},
+}
+
+func BenchmarkRenderPDF(b *testing.B) {
+	manifest := benchManifests(1, 20)[0]
+	for _, fonts := range benchFonts {
+		config := DefaultConfig()
+		config.Fonts = fonts.fonts
+
+		b.Run(fonts.name+"/cold", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				resetFontCache()
+				b.StartTimer()
+				if _, err := RenderPDF(context.Background(), manifest, config); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fonts.name+"/warm", func(b *testing.B) {
+			resetFontCache()
+			if _, err := RenderPDF(context.Background(), manifest, config); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := RenderPDF(context.Background(), manifest, config); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkGenerateBatch compares the zones of a batch saved as GenerateBatch does,
+// with the cache emptied before every zone as if there were none, or only before the
+// batch. The report and bundle, the same for both, are left out.
+func BenchmarkGenerateBatch(b *testing.B) {
+	manifests := benchManifests(10, 20)
+	for _, fonts := range benchFonts {
+		settings := DefaultSettings()
+		settings.Config.Fonts = fonts.fonts
+		settings.Config.Output = b.TempDir()
+
+		b.Run(fonts.name+"/uncached", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, manifest := range manifests {
+					b.StopTimer()
+					resetFontCache()
+					b.StartTimer()
+					if _, _, err := saveZone(manifest, settings); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+
+		b.Run(fonts.name+"/cached", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				resetFontCache()
+				b.StartTimer()
+				for _, manifest := range manifests {
+					if _, _, err := saveZone(manifest, settings); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFontCacheReadsChangedFiles(t *testing.T) {
+	resetFontCache()
+	path := filepath.Join(t.TempDir(), "font.ttf")
+	write := func(data []byte, modTime time.Time) fs.FileInfo {
+		t.Helper()
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+	face := FontFace{Path: path}
+	start := time.Now().Add(-time.Hour)
+
+	info := write(goregular.TTF, start)
+	faces := sharedFonts.fileFaces(path, info)
+	if len(faces) != 1 || faces[0].Subfamily != "Regular" {
+		t.Fatalf("faces %v, want the regular face", faces)
+	}
+	font, err := sharedFonts.font(face)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(font.Data, goregular.TTF) {
+		t.Fatal("font read with other data")
+	}
+	if again, _ := sharedFonts.font(face); again != font {
+		t.Error("unchanged font read again")
+	}
+
+	// Replacing the file is noticed by its size and modification time
+	info = write(gobold.TTF, start.Add(time.Minute))
+	faces = sharedFonts.fileFaces(path, info)
+	if len(faces) != 1 || faces[0].Subfamily != "Bold" {
+		t.Errorf("faces %v after the change, want the bold face", faces)
+	}
+	font, err = sharedFonts.font(face)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(font.Data, gobold.TTF) {
+		t.Error("old font returned after the file changed")
+	}
+}
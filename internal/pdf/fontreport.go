@@ -0,0 +1,132 @@
+package pdf
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// styleNames are the names of the font styles in reports, in the order they are listed
+var styleNames = []struct {
+	style FontStyle
+	name  string
+}{
+	{FontRegular, "regular"},
+	{FontBold, "bold"},
+	{FontItalic, "italic"},
+	{FontBoldItalic, "bold italic"},
+}
+
+// WriteFontReport describes how fonts are chosen for config: the directories searched,
+// the faces found, the candidate families, the chain each style is drawn with and,
+// when sample is not empty, which fonts cover its characters
+func WriteFontReport(w io.Writer, config *PDFConfig, sample string) error {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	var sb strings.Builder
+
+	sb.WriteString("Font directories:\n")
+	for _, dir := range fontDirs() {
+		status := "found"
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			status = "missing"
+		}
+		fmt.Fprintf(&sb, "  %s (%s)\n", dir, status)
+	}
+
+	faces := ScanFonts()
+	fmt.Fprintf(&sb, "\nFonts found: %d\n", len(faces))
+	for _, face := range faces {
+		fmt.Fprintf(&sb, "  %s: %s %s\n", faceName(face), face.Family, face.Subfamily)
+	}
+
+	families := preferredFamilies
+	if config.Fonts.Family != "" {
+		families = append([]string{config.Fonts.Family}, preferredFamilies...)
+	}
+	sb.WriteString("\nCandidate families:\n")
+	for _, family := range families {
+		fmt.Fprintf(&sb, "  %s\n", family)
+		for _, style := range styleNames {
+			path := "not found"
+			if face, ok := findFace(faces, family, style.style); ok {
+				path = faceName(face)
+			}
+			fmt.Fprintf(&sb, "    %-12s %s\n", style.name+":", path)
+		}
+	}
+
+	chain, reason, err := loadFonts(config.Fonts)
+	if err != nil {
+		fmt.Fprintf(&sb, "\nCould not load fonts: %v\n", err)
+		_, writeErr := io.WriteString(w, sb.String())
+		return writeErr
+	}
+	fmt.Fprintf(&sb, "\nChosen fonts: %s\n", reason)
+	for _, style := range styleNames {
+		var names []string
+		for _, font := range chain.fonts[style.style] {
+			names = append(names, font.Name)
+		}
+		fmt.Fprintf(&sb, "  %-12s %s\n", style.name+":", strings.Join(names, ", "))
+	}
+
+	if sample != "" {
+		writeCoverage(&sb, chain, sample, config.MissingGlyph)
+	}
+
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+// writeCoverage reports for each style how many distinct characters of sample each font
+// of the chain draws, and what is printed for the ones none covers
+func writeCoverage(sb *strings.Builder, chain *FontChain, sample, replacement string) {
+	var chars []rune
+	for _, r := range sample {
+		if !unicode.IsSpace(r) && !unicode.IsControl(r) && !slices.Contains(chars, r) {
+			chars = append(chars, r)
+		}
+	}
+
+	fmt.Fprintf(sb, "\nCoverage of %d distinct characters:\n", len(chars))
+	for _, style := range styleNames {
+		fonts := chain.fonts[style.style]
+		counts := make([]int, len(fonts))
+		var missing []string
+		for _, r := range chars {
+			if index, ok := chain.fontFor(r, style.style); ok {
+				counts[index]++
+				continue
+			}
+			stand, ok := transliterations[r]
+			if !ok {
+				stand = replacement
+			}
+			missing = append(missing, fmt.Sprintf("%c (U+%04X) as %q", r, r, stand))
+		}
+
+		fmt.Fprintf(sb, "  %s: %d of %d covered\n", style.name, len(chars)-len(missing), len(chars))
+		for i, font := range fonts {
+			if counts[i] > 0 {
+				fmt.Fprintf(sb, "    %d by %s\n", counts[i], font.Name)
+			}
+		}
+		for _, char := range missing {
+			fmt.Fprintf(sb, "    missing %s\n", char)
+		}
+	}
+}
+
+// faceName returns the path of a face, with its index for the faces of a collection
+func faceName(face FontFace) string {
+	if strings.EqualFold(filepath.Ext(face.Path), ".ttc") {
+		return fmt.Sprintf("%s#%d", face.Path, face.Index)
+	}
+	return face.Path
+}
@@ -0,0 +1,199 @@
+package pdf
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// FontFace is a font found on the system, with the names it declares. Faces of a
+// TrueType collection (.ttc) share its path and differ by their index in it.
+type FontFace struct {
+	Path      string
+	Index     int
+	Family    string
+	Subfamily string // Style as named by the font, such as "Regular", "Book" or "Bold"
+}
+
+// Style returns the font style of the face, and false for styles such as light or
+// condensed that are not used
+func (f FontFace) Style() (FontStyle, bool) {
+	switch strings.ToLower(f.Subfamily) {
+	case "regular", "book", "normal", "roman":
+		return FontRegular, true
+	case "bold":
+		return FontBold, true
+	case "italic", "oblique", "book italic", "book oblique":
+		return FontItalic, true
+	case "bold italic", "bold oblique":
+		return FontBoldItalic, true
+	}
+	return FontRegular, false
+}
+
+// ScanFonts returns the fonts installed in the system and user font directories. The
+// directories are walked on every call to notice installed and removed fonts, but the
+// names are only read from files that are new or have changed.
+func ScanFonts() []FontFace {
+	return scanFontDirs(fontDirs())
+}
+
+// scanFontDirs reads the family and style of every TrueType font and collection under dirs
+func scanFontDirs(dirs []string) []FontFace {
+	var faces []FontFace
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || seen[path] {
+				return nil
+			}
+			seen[path] = true
+
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".ttf", ".ttc":
+				if info, err := entry.Info(); err == nil {
+					faces = append(faces, sharedFonts.fileFaces(path, info)...)
+				}
+			}
+			return nil
+		})
+	}
+	return faces
+}
+
+// ReadFontFaces reads the family and style names of the faces in a font file or
+// collection, loading only the tables it needs. Unreadable files have no faces.
+func ReadFontFaces(path string) []FontFace {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	collection, err := sfnt.ParseCollectionReaderAt(file)
+	if err != nil {
+		return nil
+	}
+
+	var faces []FontFace
+	for i := 0; i < collection.NumFonts(); i++ {
+		font, err := collection.Font(i)
+		if err != nil {
+			continue
+		}
+		faces = append(faces, FontFace{
+			Path:      path,
+			Index:     i,
+			Family:    fontName(font, sfnt.NameIDTypographicFamily, sfnt.NameIDFamily),
+			Subfamily: fontName(font, sfnt.NameIDTypographicSubfamily, sfnt.NameIDSubfamily),
+		})
+	}
+	return faces
+}
+
+// fontName returns the first of the name table entries a font defines
+func fontName(font *sfnt.Font, ids ...sfnt.NameID) string {
+	for _, id := range ids {
+		if name, err := font.Name(nil, id); err == nil && name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// fontDirs returns the directories fonts are installed in on this system, user
+// directories first, each listed once
+func fontDirs() []string {
+	var dirs []string
+	for _, dir := range systemFontDirs() {
+		if !slices.Contains(dirs, filepath.Clean(dir)) {
+			dirs = append(dirs, filepath.Clean(dir))
+		}
+	}
+	return dirs
+}
+
+// systemFontDirs returns the directories fonts are installed in on this system
+func systemFontDirs() []string {
+	home, _ := os.UserHomeDir()
+
+	switch runtime.GOOS {
+	case "windows":
+		return []string{
+			filepath.Join(os.Getenv("LOCALAPPDATA"), "Microsoft", "Windows", "Fonts"),
+			filepath.Join(os.Getenv("WINDIR"), "Fonts"),
+		}
+	case "darwin":
+		return []string{
+			filepath.Join(home, "Library", "Fonts"),
+			"/Library/Fonts",
+			"/System/Library/Fonts",
+		}
+	}
+
+	// XDG base directories, as searched by fontconfig
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+
+	dirs := []string{filepath.Join(dataHome, "fonts"), filepath.Join(home, ".fonts")}
+	for _, dir := range filepath.SplitList(dataDirs) {
+		dirs = append(dirs, filepath.Join(dir, "fonts"))
+	}
+	return append(dirs, fontconfigDirs(home)...)
+}
+
+// fontconfigDir matches the <dir> elements of a fontconfig file
+var fontconfigDir = regexp.MustCompile(`<dir(?:\s+prefix="(\w+)")?[^>]*>\s*([^<]+?)\s*</dir>`)
+
+// fontconfigDirs returns the extra font directories listed in the fontconfig files
+func fontconfigDirs(home string) []string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+
+	var dirs []string
+	for _, path := range []string{"/etc/fonts/fonts.conf", "/etc/fonts/local.conf", filepath.Join(configHome, "fontconfig", "fonts.conf")} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, match := range fontconfigDir.FindAllStringSubmatch(string(data), -1) {
+			dir := match[2]
+			switch {
+			case match[1] == "xdg":
+				dir = filepath.Join(os.Getenv("XDG_DATA_HOME"), dir)
+				if os.Getenv("XDG_DATA_HOME") == "" {
+					dir = filepath.Join(home, ".local", "share", match[2])
+				}
+			case strings.HasPrefix(dir, "~"):
+				dir = filepath.Join(home, dir[1:])
+			}
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// findFace returns the face of a family in a style, comparing family names without
+// regard to case
+func findFace(faces []FontFace, family string, style FontStyle) (FontFace, bool) {
+	for _, face := range faces {
+		if faceStyle, ok := face.Style(); ok && faceStyle == style && strings.EqualFold(face.Family, family) {
+			return face, true
+		}
+	}
+	return FontFace{}, false
+}
@@ -0,0 +1,106 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// fontTable is a table of a TrueType font, such as "glyf" or "head"
+type fontTable struct {
+	tag  string
+	data []byte
+}
+
+// readFontTables returns the version and tables of the font whose offset table starts
+// at offset in data. Table offsets are relative to the start of data, as in collections.
+func readFontTables(data []byte, offset int) ([]byte, []fontTable, error) {
+	if offset < 0 || len(data) < offset+12 {
+		return nil, nil, fmt.Errorf("font header is truncated")
+	}
+	numTables := int(binary.BigEndian.Uint16(data[offset+4:]))
+	if len(data) < offset+12+16*numTables {
+		return nil, nil, fmt.Errorf("font table directory is truncated")
+	}
+
+	tables := make([]fontTable, numTables)
+	for i := range tables {
+		record := data[offset+12+16*i:]
+		tableOffset := int(binary.BigEndian.Uint32(record[8:]))
+		tableLength := int(binary.BigEndian.Uint32(record[12:]))
+		if tableOffset < 0 || tableLength < 0 || len(data) < tableOffset+tableLength {
+			return nil, nil, fmt.Errorf("font table %q is out of the file", record[:4])
+		}
+		tables[i] = fontTable{tag: string(record[:4]), data: data[tableOffset : tableOffset+tableLength]}
+	}
+	return data[offset : offset+4], tables, nil
+}
+
+// findFontTable returns the table with the tag, and nil when the font has none
+func findFontTable(tables []fontTable, tag string) []byte {
+	for _, table := range tables {
+		if table.tag == tag {
+			return table.data
+		}
+	}
+	return nil
+}
+
+// writeFont assembles a standalone font from its tables, in the given order, with the
+// table checksums and the head checksum adjustment computed over the new file
+func writeFont(version []byte, tables []fontTable) []byte {
+	// The search fields of the offset table describe a binary search over the records
+	numTables := len(tables)
+	searchRange, entrySelector := 16, 0
+	for searchRange*2 <= numTables*16 {
+		searchRange *= 2
+		entrySelector++
+	}
+
+	var out bytes.Buffer
+	out.Write(version)
+	binary.Write(&out, binary.BigEndian, []uint16{uint16(numTables), uint16(searchRange), uint16(entrySelector), uint16(numTables*16 - searchRange)})
+	out.Write(make([]byte, 16*numTables))
+
+	headOffset := -1
+	positions := make([]int, numTables)
+	for i, table := range tables {
+		positions[i] = out.Len()
+		if table.tag == "head" {
+			headOffset = positions[i]
+		}
+		out.Write(table.data)
+		for out.Len()%4 != 0 {
+			out.WriteByte(0)
+		}
+	}
+
+	// The checksums cover the head table with its adjustment cleared
+	font := out.Bytes()
+	if headOffset >= 0 && headOffset+12 <= len(font) {
+		binary.BigEndian.PutUint32(font[headOffset+8:], 0)
+	}
+	for i, table := range tables {
+		record := font[12+16*i:]
+		copy(record, table.tag)
+		binary.BigEndian.PutUint32(record[4:], tableChecksum(font[positions[i]:positions[i]+(len(table.data)+3)&^3]))
+		binary.BigEndian.PutUint32(record[8:], uint32(positions[i]))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(table.data)))
+	}
+	if headOffset >= 0 && headOffset+12 <= len(font) {
+		binary.BigEndian.PutUint32(font[headOffset+8:], 0xB1B0AFBA-tableChecksum(font))
+	}
+
+	return font
+}
+
+// tableChecksum adds up data as big-endian 32-bit words, padding the last one with zeros
+func tableChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
@@ -0,0 +1,52 @@
+package pdf
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// GlyphChecker is implemented by measurers that can tell whether a character can be
+// drawn in a font style
+type GlyphChecker interface {
+	HasGlyph(r rune, style FontStyle) bool
+}
+
+// transliterations are the plain text stand-ins for characters fonts often lack
+var transliterations = map[rune]string{
+	'‘': "'", '’': "'", '‚': ",", '“': "\"", '”': "\"", '„': "\"",
+	'–': "-", '—': "-", '…': "...", '•': "*", '·': ".", '€': "EUR",
+	'«': "\"", '»': "\"", 'œ': "oe", 'Œ': "OE", 'æ': "ae", 'Æ': "AE",
+	'✓': "v", '✔': "v", '✗': "x", '✘': "x", '→': "->", '←': "<-",
+	'☺': ":)", '☹': ":(", '♥': "<3", '❤': "<3",
+	'🙂': ":)", '😊': ":)", '😀': ":D", '😃': ":D", '😄': ":D", '😁': ":D",
+	'😂': ":'D", '🤣': ":'D", '😉': ";)", '😢': ":'(", '😭': ":'(", '🥲': ":')",
+	'😞': ":(", '🙁': ":(", '😅': "^^'", '😍': "<3", '😘': ":*", '😎': "B)",
+	'👍': "(y)", '👎': "(n)", '🙏': "(merci)", '💯': "100", '🔥': "(!)",
+}
+
+// coverText replaces the grapheme clusters checker cannot draw with a transliteration
+// of their first character, or with replacement when there is none. Marks and joiners
+// the fonts lack are dropped, leaving the character they modify.
+func coverText(text string, style FontStyle, checker GlyphChecker, replacement string) string {
+	var sb strings.Builder
+	for _, cluster := range graphemes(text) {
+		base, size := utf8.DecodeRuneInString(cluster)
+		if !checker.HasGlyph(base, style) && !unicode.IsSpace(base) {
+			if stand, ok := transliterations[base]; ok {
+				sb.WriteString(stand)
+			} else {
+				sb.WriteString(replacement)
+			}
+			continue
+		}
+
+		sb.WriteRune(base)
+		for _, r := range cluster[size:] {
+			if checker.HasGlyph(r, style) {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	return sb.String()
+}
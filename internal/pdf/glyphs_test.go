@@ -0,0 +1,126 @@
+package pdf
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// testFontChain returns the bundled Noto Sans chain followed by Go Regular, which has
+// the arrows and math signs Noto Sans lacks. Neither has emoji, ✓ or 李.
+func testFontChain(t *testing.T) *FontChain {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "Go-Regular.ttf")
+	if err := os.WriteFile(path, goregular.TTF, 0644); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := EmbeddedFontChain([]FontFace{{Path: path, Family: "Go", Subfamily: "Regular"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chain
+}
+
+func TestFontChainRuns(t *testing.T) {
+	chain := testFontChain(t)
+
+	for _, test := range []struct {
+		text string
+		want []fontRun
+	}{
+		{"Ambôhy", []fontRun{{0, "Ambôhy"}}},
+		{"Vola → 15000", []fontRun{{0, "Vola "}, {1, "→"}, {0, " 15000"}}},
+		{"≈→", []fontRun{{1, "≈→"}}},
+		// Characters no font has stay in the primary font, to be covered by coverText
+		{"ok 🥲", []fontRun{{0, "ok 🥲"}}},
+		{"", nil},
+	} {
+		if got := chain.runs(test.text, FontRegular); !slices.Equal(got, test.want) {
+			t.Errorf("runs(%q) = %v, want %v", test.text, got, test.want)
+		}
+	}
+
+	for _, style := range []FontStyle{FontRegular, FontBold, FontItalic, FontBoldItalic} {
+		if font, ok := chain.fontFor('→', style); !ok || font != 1 {
+			t.Errorf("→ in style %v drawn with font %d, want the fallback", style, font)
+		}
+		if _, ok := chain.fontFor('🥲', style); ok {
+			t.Errorf("🥲 in style %v found in a font", style)
+		}
+	}
+}
+
+func TestCoverText(t *testing.T) {
+	withFallback := testFontChain(t)
+	alone, err := EmbeddedFontChain(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		chain *FontChain
+		text  string
+		want  string
+	}{
+		// A fallback that has the character keeps it
+		{withFallback, "Vola → 15000 ≈ œ", "Vola → 15000 ≈ œ"},
+		// Without one it is transliterated, or replaced when there is no stand-in
+		{alone, "Vola → 15000 ≈ œ", "Vola -> 15000 ? œ"},
+		{withFallback, "Misaotra 🥲 ✓ 李", "Misaotra :') v ?"},
+		{alone, "Misaotra 🥲 ✓ 李", "Misaotra :') v ?"},
+		// A flag or a family is one character, replaced once
+		{withFallback, "🇲🇬 👨‍👩‍👧", "? ?"},
+		// A skin tone the font lacks is dropped from a character it has
+		{withFallback, "→\U0001F3FD", "→"},
+	} {
+		if got := coverText(test.text, FontRegular, test.chain, "?"); got != test.want {
+			t.Errorf("coverText(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestGopdfRendererEmbedsFallbacksWhenUsed(t *testing.T) {
+	chain := testFontChain(t)
+	for _, test := range []struct {
+		text string
+		want []string
+	}{
+		{"Ambôhy", []string{"regular"}},
+		{"Vola → 15000", []string{"regular", "regular-1"}},
+		{"≈", []string{"regular-1"}},
+	} {
+		renderer, err := NewGopdfRenderer(chain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if width := renderer.MeasureText(test.text, FontRegular, 10); width <= 0 {
+			t.Errorf("%q measured %v", test.text, width)
+		}
+		var registered []string
+		for family := range renderer.registered {
+			registered = append(registered, family)
+		}
+		slices.Sort(registered)
+		if !slices.Equal(registered, test.want) {
+			t.Errorf("%q registered %v, want %v", test.text, registered, test.want)
+		}
+	}
+
+	// The fallback font is embedded in the PDF
+	doc := &Document{Width: 78, Height: 20, Blocks: []Block{{Texts: []TextRun{{Text: "Vola → 15000", X: 4, Y: 4, Size: 10}}}}}
+	renderer, err := NewGopdfRenderer(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := renderer.Render(doc, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("regular-1")) {
+		t.Error("the fallback font is not in the PDF")
+	}
+}
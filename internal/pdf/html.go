@@ -0,0 +1,125 @@
+package pdf
+
+import (
+	"bytes"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+)
+
+//go:embed templates/manifest.html
+var htmlTemplates embed.FS
+
+var manifestTemplate = template.Must(template.ParseFS(htmlTemplates, "templates/manifest.html"))
+
+// HTMLConfig selects the paper the HTML manifest is printed on
+type HTMLConfig struct {
+	Paper string // "58mm", "78mm", "80mm" or "a4"
+}
+
+// DefaultHTMLConfig returns settings for 80mm thermal rolls
+func DefaultHTMLConfig() *HTMLConfig {
+	return &HTMLConfig{Paper: "80mm"}
+}
+
+// htmlEntry holds the formatted fields of one entry for the HTML template
+type htmlEntry struct {
+	Name    string
+	Total   string
+	ID      string
+	Address string
+	Phone   string
+	Items   []string
+	Notes   []textSpan
+}
+
+// htmlManifest is the data the HTML template is executed with
+type htmlManifest struct {
+	Zone       string
+	Date       string
+	Details    []string
+	Notes      [][]textSpan
+	Paper      string
+	PageSize   string
+	PageMargin string
+	Logo       template.URL
+	Labels     Labels
+	Entries    []htmlEntry
+}
+
+// RenderHTML writes the manifest as a self-contained HTML page with a print stylesheet
+func RenderHTML(w io.Writer, manifest *Manifest, config *PDFConfig, html *HTMLConfig) error {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if html == nil {
+		html = DefaultHTMLConfig()
+	}
+
+	data := htmlManifest{
+		Zone:    manifest.Zone,
+		Date:    manifest.Day().Format("02/01/2006"),
+		Details: manifest.headerLines(&config.Labels),
+		Labels:  config.Labels,
+	}
+	for _, note := range strings.Split(manifest.Notes, "\n") {
+		if strings.TrimSpace(note) != "" {
+			data.Notes = append(data.Notes, parseEmphasis(note, FontRegular))
+		}
+	}
+
+	// CSS page sizes take two lengths and no auto height, so rolls print on pages as
+	// long as an A4 sheet, entries being kept whole across pages
+	switch html.Paper {
+	case "58mm":
+		data.Paper, data.PageSize, data.PageMargin = "58mm", "58mm 297mm", "2mm"
+	case "78mm":
+		data.Paper, data.PageSize, data.PageMargin = "78mm", "78mm 297mm", "4mm"
+	case "80mm":
+		data.Paper, data.PageSize, data.PageMargin = "80mm", "80mm 297mm", "4mm"
+	case "a4", "A4":
+		data.Paper, data.PageSize, data.PageMargin = "a4", "A4", "15mm"
+	default:
+		return fmt.Errorf("unknown paper %q, expected 58mm, 78mm, 80mm or a4", html.Paper)
+	}
+
+	// Inline the logo so the file stays self-contained
+	if logo, err := os.ReadFile("assets/logo.png"); err == nil {
+		data.Logo = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(logo))
+	}
+
+	for _, entry := range manifest.Entries {
+		phone := entry.Phone
+		if phone == "" {
+			phone = config.Labels.NoPhone
+		}
+
+		data.Entries = append(data.Entries, htmlEntry{
+			Name:    entry.Name,
+			Total:   FormatAmount(entry.CalculateTotal(), &config.Labels),
+			ID:      FormatID(entry.ID, &config.Labels),
+			Address: entry.Address,
+			Phone:   phone,
+			Items:   formatItems(entry, &config.Labels),
+			Notes:   parseEmphasis(entry.Notes, FontRegular),
+		})
+	}
+
+	return manifestTemplate.Execute(w, data)
+}
+
+// ExportHTML saves the HTML manifest next to the PDFs and returns its path
+func ExportHTML(manifest *Manifest, config *PDFConfig, html *HTMLConfig) (string, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	var buf bytes.Buffer
+	if err := RenderHTML(&buf, manifest, config, html); err != nil {
+		return "", err
+	}
+	return saveFile(config.Output, "fanatitra", manifest, ".html", buf.Bytes())
+}
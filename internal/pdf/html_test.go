@@ -0,0 +1,35 @@
+package pdf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHTMLPageSize(t *testing.T) {
+	manifest := &Manifest{
+		Zone:    "Analakely",
+		Entries: []DeliveryEntry{{ID: "001", Name: "Rakoto", Items: "12+3"}},
+	}
+	for _, test := range []struct {
+		paper string
+		page  string
+	}{
+		{"58mm", "@page { size: 58mm 297mm; margin: 2mm; }"},
+		{"78mm", "@page { size: 78mm 297mm; margin: 4mm; }"},
+		{"80mm", "@page { size: 80mm 297mm; margin: 4mm; }"},
+		{"a4", "@page { size: A4; margin: 15mm; }"},
+	} {
+		var buf bytes.Buffer
+		if err := RenderHTML(&buf, manifest, nil, &HTMLConfig{Paper: test.paper}); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), test.page) {
+			t.Errorf("%s: page rule %q not found", test.paper, test.page)
+		}
+	}
+
+	if err := RenderHTML(&bytes.Buffer{}, manifest, nil, &HTMLConfig{Paper: "a5"}); err == nil {
+		t.Error("an unknown paper was accepted")
+	}
+}
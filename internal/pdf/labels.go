@@ -0,0 +1,304 @@
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/signintech/gopdf"
+)
+
+// LabelSheetConfig describes a sheet of adhesive labels laid out in a grid
+type LabelSheetConfig struct {
+	PageWidth       float64    `toml:"page_width"`
+	PageHeight      float64    `toml:"page_height"`
+	Rows            int        `toml:"rows"`
+	Columns         int        `toml:"columns"`
+	MarginTop       float64    `toml:"margin_top"`
+	MarginLeft      float64    `toml:"margin_left"`
+	LabelWidth      float64    `toml:"label_width"`
+	LabelHeight     float64    `toml:"label_height"`
+	HorizontalPitch float64    `toml:"horizontal_pitch"` // Distance between the left edges of two neighbouring labels
+	VerticalPitch   float64    `toml:"vertical_pitch"`   // Distance between the top edges of two neighbouring labels
+	Padding         float64    `toml:"padding"`
+	OffsetX         float64    `toml:"offset_x"` // Calibration shift for printers that feed the sheet slightly off
+	OffsetY         float64    `toml:"offset_y"`
+	LineHeight      float64    `toml:"line_height"`
+	ShowBarcode     bool       `toml:"show_barcode"`
+	BarcodeHeight   float64    `toml:"barcode_height"`
+	ShowBorders     bool       `toml:"show_borders"` // Dashed outlines to check the calibration on plain paper
+	Labels          Labels     `toml:"-"`            // Captions, fonts and output directory come from the PDF config, see Settings.LabelSheetFor
+	Fonts           FontConfig `toml:"-"`
+	MissingGlyph    string     `toml:"-"` // Drawn for characters no font covers, as PDFConfig.MissingGlyph
+	Output          string     `toml:"-"` // Directory sheets are saved to, as PDFConfig.Output
+}
+
+// DefaultLabelSheetConfig returns an A4 sheet of 3x8 labels of 70x36mm
+func DefaultLabelSheetConfig() *LabelSheetConfig {
+	return &LabelSheetConfig{
+		PageWidth:       210.0,
+		PageHeight:      297.0,
+		Rows:            8,
+		Columns:         3,
+		MarginTop:       4.5,
+		MarginLeft:      0.0,
+		LabelWidth:      70.0,
+		LabelHeight:     36.0,
+		HorizontalPitch: 70.0,
+		VerticalPitch:   36.0,
+		Padding:         3.0,
+		OffsetX:         0.0,
+		OffsetY:         0.0,
+		LineHeight:      3.5,
+		ShowBarcode:     true,
+		BarcodeHeight:   6.0,
+		ShowBorders:     false,
+		Labels:          DefaultLabels(),
+		MissingGlyph:    "?",
+	}
+}
+
+// Validate rejects label sheets whose grid cannot be drawn, naming the offending field
+func (c *LabelSheetConfig) Validate() error {
+	if c.Rows <= 0 || c.Columns <= 0 {
+		return fmt.Errorf("label sheet needs at least one row and one column, got %d rows and %d columns", c.Rows, c.Columns)
+	}
+
+	positive := []struct {
+		name  string
+		value float64
+	}{
+		{"PageWidth", c.PageWidth},
+		{"PageHeight", c.PageHeight},
+		{"LabelWidth", c.LabelWidth},
+		{"LabelHeight", c.LabelHeight},
+		{"LineHeight", c.LineHeight},
+	}
+	for _, field := range positive {
+		if field.value <= 0 {
+			return fmt.Errorf("%s must be positive, got %v", field.name, field.value)
+		}
+	}
+
+	notNegative := []struct {
+		name  string
+		value float64
+	}{
+		{"MarginTop", c.MarginTop},
+		{"MarginLeft", c.MarginLeft},
+		{"HorizontalPitch", c.HorizontalPitch},
+		{"VerticalPitch", c.VerticalPitch},
+		{"Padding", c.Padding},
+		{"BarcodeHeight", c.BarcodeHeight},
+	}
+	for _, field := range notNegative {
+		if field.value < 0 {
+			return fmt.Errorf("%s must not be negative, got %v", field.name, field.value)
+		}
+	}
+
+	return nil
+}
+
+// GenerateLabels saves one address label per entry on A4 label sheets in the output
+// directory and returns the path of the file
+func GenerateLabels(manifest *Manifest, config *LabelSheetConfig) (string, error) {
+	if config == nil {
+		config = DefaultLabelSheetConfig()
+	}
+	var buf bytes.Buffer
+	if err := WriteLabels(context.Background(), &buf, manifest, config); err != nil {
+		return "", err
+	}
+
+	return saveFile(config.Output, "marika", manifest, ".pdf", buf.Bytes())
+}
+
+// WriteLabels writes one address label per entry of a manifest on A4 label sheets to w.
+// It gives up with the context's error once ctx is done.
+func WriteLabels(ctx context.Context, w io.Writer, manifest *Manifest, config *LabelSheetConfig) error {
+	if config == nil {
+		config = DefaultLabelSheetConfig()
+	}
+
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("invalid label sheet config: %v", err)
+	}
+
+	fonts, err := LoadFonts(config.Fonts)
+	if err != nil {
+		return err
+	}
+	renderer, err := NewGopdfRenderer(fonts)
+	if err != nil {
+		return err
+	}
+
+	perSheet := config.Rows * config.Columns
+	for i, entry := range manifest.Entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if i%perSheet == 0 {
+			renderer.pdf.AddPageWithOption(gopdf.PageOption{
+				PageSize: &gopdf.Rect{W: config.PageWidth, H: config.PageHeight},
+			})
+		}
+
+		slot := i % perSheet
+		row := slot / config.Columns
+		col := slot % config.Columns
+		x := config.MarginLeft + config.OffsetX + float64(col)*config.HorizontalPitch
+		y := config.MarginTop + config.OffsetY + float64(row)*config.VerticalPitch
+
+		if err := drawLabel(renderer, manifest.Zone, entry, x, y, config); err != nil {
+			return err
+		}
+	}
+	if renderer.err != nil {
+		return renderer.err
+	}
+
+	_, err = renderer.pdf.WriteTo(w)
+	return err
+}
+
+// drawLabel draws a single parcel label with its top left corner at x, y. Text goes
+// through the font chain like the manifest, with the characters no font covers replaced.
+func drawLabel(r *GopdfRenderer, zone string, entry DeliveryEntry, x, y float64, config *LabelSheetConfig) error {
+	pdf := r.pdf
+	if config.ShowBorders {
+		pdf.SetLineWidth(0.1)
+		pdf.SetLineType("dashed")
+		pdf.RectFromUpperLeft(x, y, config.LabelWidth, config.LabelHeight)
+		pdf.SetLineType("solid")
+	}
+
+	left := x + config.Padding
+	right := x + config.LabelWidth - config.Padding
+	bottom := y + config.LabelHeight - config.Padding
+	innerWidth := right - left
+	currentY := y + config.Padding
+
+	measure := func(text string, style FontStyle, size float64) float64 {
+		return r.MeasureText(coverText(text, style, r, config.MissingGlyph), style, size)
+	}
+	text := func(x, y float64, text string, style FontStyle, size float64) error {
+		return r.drawText(x, y, coverText(text, style, r, config.MissingGlyph), style, size)
+	}
+	textRight := func(x, y float64, s string, style FontStyle, size float64) error {
+		return text(x-measure(s, style, size), y, s, style, size)
+	}
+	wrapAt := func(s string, style FontStyle, size, maxWidth float64) []string {
+		return wrapText(func(part string) float64 { return measure(part, style, size) }, s, maxWidth)
+	}
+
+	// Name with the amount to collect on the right
+	totalText := FormatAmount(entry.CalculateTotal(), &config.Labels)
+	totalWidth := measure(totalText, FontBold, 9)
+	nameLines := wrapAt(entry.Name, FontBold, 9, innerWidth-totalWidth-2)
+	if len(nameLines) > 0 {
+		if err := text(left, currentY, nameLines[0], FontBold, 9); err != nil {
+			return err
+		}
+	}
+	if err := text(right-totalWidth, currentY, totalText, FontBold, 9); err != nil {
+		return err
+	}
+	currentY += config.LineHeight + 0.5
+
+	// ID on the left, zone on the right
+	if err := text(left, currentY, FormatID(entry.ID, &config.Labels), FontRegular, 7); err != nil {
+		return err
+	}
+	if err := textRight(right, currentY, zone, FontRegular, 7); err != nil {
+		return err
+	}
+	currentY += config.LineHeight
+
+	// Phone number
+	phone := entry.Phone
+	if phone == "" {
+		phone = config.Labels.NoPhone
+	}
+	if err := text(left, currentY, "#", FontRegular, 8); err != nil {
+		return err
+	}
+	if err := text(left+4, currentY, phone, FontRegular, 8); err != nil {
+		return err
+	}
+	currentY += config.LineHeight
+
+	// Reserve room for the barcode at the bottom of the label. A barcode whose bars
+	// would be too thin to scan is left out, the ID being printed above anyway.
+	barcode, err := encodeCode128(strings.TrimSpace(entry.ID))
+	showBarcode := config.ShowBarcode && err == nil
+	var barX, moduleWidth float64
+	if showBarcode {
+		barX, moduleWidth, showBarcode = placeBarcode(barcodeModules(barcode), x, config)
+	}
+	textBottom := bottom
+	if showBarcode {
+		textBottom -= config.BarcodeHeight + 1
+	}
+
+	// Address, cut to the lines that fit on the label
+	for i, line := range wrapAt(entry.Address, FontRegular, 8, innerWidth-4) {
+		if currentY+config.LineHeight > textBottom {
+			break
+		}
+		if i == 0 {
+			if err := text(left, currentY, ">", FontRegular, 8); err != nil {
+				return err
+			}
+		}
+		if err := text(left+4, currentY, line, FontRegular, 8); err != nil {
+			return err
+		}
+		currentY += config.LineHeight
+	}
+
+	if showBarcode {
+		drawBarcode(pdf, barcode, barX, bottom-config.BarcodeHeight, moduleWidth, config.BarcodeHeight)
+	}
+	return nil
+}
+
+// Code 128 bars are drawn 0.4mm wide per module when they fit, and never narrower than
+// scanners read reliably from labels printed on office printers
+const (
+	barcodeModuleWidth    = 0.4
+	barcodeMinModuleWidth = 0.25
+	barcodeQuietModules   = 10 // Blank modules Code 128 needs on each side of the bars
+)
+
+// placeBarcode returns where the bars of a barcode of modules start on the label whose
+// left edge is at x, and the width of a module, leaving the quiet zone blank on both
+// sides and the padding too. It returns false when the bars would be too narrow.
+func placeBarcode(modules int, x float64, config *LabelSheetConfig) (float64, float64, bool) {
+	moduleWidth := min(
+		barcodeModuleWidth,
+		(config.LabelWidth-2*config.Padding)/float64(modules),
+		config.LabelWidth/float64(modules+2*barcodeQuietModules),
+	)
+	if moduleWidth < barcodeMinModuleWidth {
+		return 0, 0, false
+	}
+	return x + max(config.Padding, barcodeQuietModules*moduleWidth), moduleWidth, true
+}
+
+// drawBarcode draws the bars of an encoded barcode from x, moduleWidth per module
+func drawBarcode(pdf *gopdf.GoPdf, widths []int, x, y, moduleWidth, height float64) {
+	pdf.SetFillColor(0, 0, 0)
+	currentX := x
+	for i, w := range widths {
+		barWidth := float64(w) * moduleWidth
+		// Even indexes are bars, odd indexes are spaces
+		if i%2 == 0 {
+			pdf.RectFromUpperLeftWithStyle(currentX, y, barWidth, height, "F")
+		}
+		currentX += barWidth
+	}
+}
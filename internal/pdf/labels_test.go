@@ -0,0 +1,72 @@
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPlaceBarcode(t *testing.T) {
+	config := DefaultLabelSheetConfig()
+	tests := []struct {
+		id   string
+		want string // full, narrowed to fit the label or hidden
+	}{
+		{"001", "full"},
+		{"IVATO-042", "full"},
+		{"ANALAKELY-01", "narrowed"},
+		{"ANALAKELY-2026-10-19", "narrowed"},
+		{"ANALAKELY-2026-10-19-00001", "hidden"},
+	}
+
+	for _, tt := range tests {
+		widths, err := encodeCode128(tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		modules := barcodeModules(widths)
+		x, moduleWidth, ok := placeBarcode(modules, 10, config)
+
+		if tt.want == "hidden" {
+			if ok {
+				t.Errorf("%q: barcode of %.3fmm modules drawn, want it left out", tt.id, moduleWidth)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%q: barcode left out, want it %s", tt.id, tt.want)
+			continue
+		}
+		if full := moduleWidth == barcodeModuleWidth; full != (tt.want == "full") || moduleWidth < barcodeMinModuleWidth {
+			t.Errorf("%q: module width %.3fmm, want it %s", tt.id, moduleWidth, tt.want)
+		}
+
+		// The quiet zones of 10 modules and the padding are left blank on both sides
+		quiet := barcodeQuietModules * moduleWidth
+		before := x - 10
+		after := 10 + config.LabelWidth - (x + float64(modules)*moduleWidth)
+		for _, gap := range []float64{before, after} {
+			if gap < quiet-1e-9 || gap < config.Padding-1e-9 {
+				t.Errorf("%q: %.2fmm left beside the bars, want %.2fmm of quiet zone and %.2fmm of padding", tt.id, gap, quiet, config.Padding)
+			}
+		}
+	}
+}
+
+func TestWriteLabelsLeavesOutDenseBarcodes(t *testing.T) {
+	config := DefaultLabelSheetConfig()
+	config.Fonts = FontConfig{}
+	manifest := &Manifest{Zone: "Analakely", Entries: []DeliveryEntry{
+		{ID: "001", Name: "Rakoto", Address: "Lot II A 12", Phone: "0341234567", Items: "12+3"},
+		{ID: strings.Repeat("ANALAKELY-", 4), Name: "Rabe", Address: "Behoririka", Phone: "0331234567", Items: "20"},
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteLabels(context.Background(), &buf, manifest, config); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF")) {
+		t.Error("labels are not a PDF")
+	}
+}
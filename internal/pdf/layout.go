@@ -0,0 +1,674 @@
+package pdf
+
+import (
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// FontStyle selects one of the registered font faces
+type FontStyle int
+
+const (
+	FontRegular FontStyle = iota
+	FontBold
+	FontItalic
+	FontBoldItalic
+)
+
+// fontStyle returns the font style with the given weight and slant
+func fontStyle(bold, italic bool) FontStyle {
+	switch {
+	case bold && italic:
+		return FontBoldItalic
+	case bold:
+		return FontBold
+	case italic:
+		return FontItalic
+	}
+	return FontRegular
+}
+
+// Bold reports whether the style has a bold weight
+func (s FontStyle) Bold() bool {
+	return s == FontBold || s == FontBoldItalic
+}
+
+// Italic reports whether the style is slanted
+func (s FontStyle) Italic() bool {
+	return s == FontItalic || s == FontBoldItalic
+}
+
+// Align tells renderers how a text run was anchored when it was placed
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// BlockKind identifies the part of the manifest a block belongs to
+type BlockKind int
+
+const (
+	BlockHeader BlockKind = iota
+	BlockEntry
+	BlockFooter
+)
+
+// TextRun is a single line of text whose top left corner sits at X, Y
+type TextRun struct {
+	X     float64
+	Y     float64
+	Text  string
+	Style FontStyle
+	Size  float64
+	Align Align
+}
+
+// Line is a straight stroke between two points
+type Line struct {
+	X1     float64
+	Y1     float64
+	X2     float64
+	Y2     float64
+	Width  float64
+	Dashed bool
+}
+
+// Box is a rectangle whose top left corner sits at X, Y
+type Box struct {
+	X         float64
+	Y         float64
+	W         float64
+	H         float64
+	LineWidth float64
+	Dashed    bool
+	Filled    bool
+}
+
+// Image places a picture file on the page
+type Image struct {
+	Path string
+	X    float64
+	Y    float64
+	W    float64
+	H    float64
+}
+
+// Block groups the elements drawn for one part of the manifest
+type Block struct {
+	Kind   BlockKind
+	Top    float64
+	Bottom float64
+	Images []Image
+	Boxes  []Box
+	Lines  []Line
+	Texts  []TextRun
+}
+
+// shift moves the block and all of its elements down by dy
+func (blk *Block) shift(dy float64) {
+	blk.Top += dy
+	blk.Bottom += dy
+	for i := range blk.Images {
+		blk.Images[i].Y += dy
+	}
+	for i := range blk.Boxes {
+		blk.Boxes[i].Y += dy
+	}
+	for i := range blk.Lines {
+		blk.Lines[i].Y1 += dy
+		blk.Lines[i].Y2 += dy
+	}
+	for i := range blk.Texts {
+		blk.Texts[i].Y += dy
+	}
+}
+
+// Document is a fully positioned manifest, independent of any output format.
+// The pages of a paged document are stacked one below the other
+type Document struct {
+	Width      float64
+	Height     float64
+	PageHeight float64 // Height of one page, 0 when the document is a single continuous page
+	Blocks     []Block
+}
+
+// Pages returns the number of pages the document is printed on
+func (d *Document) Pages() int {
+	if d.PageHeight <= 0 {
+		return 1
+	}
+	return int(math.Ceil(d.Height / d.PageHeight))
+}
+
+// PageOf returns the zero-based page a position falls on and the position from the top of that page
+func (d *Document) PageOf(y float64) (int, float64) {
+	if d.PageHeight <= 0 {
+		return 0, y
+	}
+	page := int(y / d.PageHeight)
+	return page, y - float64(page)*d.PageHeight
+}
+
+// Measurer reports the width of a text in millimeters for a font style and size
+type Measurer interface {
+	MeasureText(text string, style FontStyle, size float64) float64
+}
+
+// Renderer writes a laid-out document in some output format
+type Renderer interface {
+	Render(doc *Document, w io.Writer) error
+}
+
+// layoutBuilder accumulates positioned elements while walking down the page
+type layoutBuilder struct {
+	config     *PDFConfig
+	template   *Template
+	typography Typography
+	measurer   Measurer
+	widths     map[measureKey]float64
+	doc        *Document
+	block      *Block
+	currentY   float64
+}
+
+// measureKey identifies a measured text in a font
+type measureKey struct {
+	text  string
+	style FontStyle
+	size  float64
+}
+
+// BuildLayout positions the header, the entries and the footer of a manifest
+func BuildLayout(manifest *Manifest, config *PDFConfig, measurer Measurer) *Document {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	template := config.Template
+	if template == nil {
+		template = DefaultTemplate()
+	}
+
+	typography := config.Typography.scaled()
+	b := &layoutBuilder{
+		config:     config.scaledSpacing(typography.Scale),
+		template:   template,
+		typography: typography,
+		measurer:   measurer,
+		widths:     make(map[measureKey]float64),
+		doc:        &Document{Width: config.PageWidth},
+		currentY:   config.MarginTop,
+	}
+
+	entries := manifest.Entries
+	b.header(manifest)
+	for i, entry := range entries {
+		b.entry(entry, i == len(entries)-1)
+	}
+	b.footer(manifest.Day())
+
+	if config.PageHeight > 0 {
+		b.doc.PageHeight = config.PageHeight
+		b.doc.Height = math.Ceil((b.currentY+config.MarginBottom)/config.PageHeight) * config.PageHeight
+		return b.doc
+	}
+
+	// Keep the historical page height unless the content needs more room
+	b.doc.Height = (float64(len(entries)) * 50) + 30
+	if contentHeight := b.currentY + config.MarginBottom; contentHeight > b.doc.Height {
+		b.doc.Height = contentHeight
+	}
+
+	return b.doc
+}
+
+// sp scales a fixed spacing or offset of the layout with the typography
+func (b *layoutBuilder) sp(mm float64) float64 {
+	return mm * b.typography.Scale
+}
+
+// startBlock opens a new block at the current position
+func (b *layoutBuilder) startBlock(kind BlockKind) {
+	b.doc.Blocks = append(b.doc.Blocks, Block{Kind: kind, Top: b.currentY})
+	b.block = &b.doc.Blocks[len(b.doc.Blocks)-1]
+}
+
+// endBlock closes the current block at the current position
+func (b *layoutBuilder) endBlock() {
+	b.block.Bottom = b.currentY
+	b.keepOnPage()
+	b.block = nil
+}
+
+// keepOnPage moves the current block to the next page when a page break would cut
+// through it, unless it is too tall to fit on any page
+func (b *layoutBuilder) keepOnPage() {
+	config := b.config
+	if config.PageHeight <= 0 {
+		return
+	}
+
+	page := math.Floor(b.block.Top / config.PageHeight)
+	pageEnd := (page+1)*config.PageHeight - config.MarginBottom
+	usable := config.PageHeight - config.MarginTop - config.MarginBottom
+	if b.block.Bottom <= pageEnd || b.block.Bottom-b.block.Top > usable {
+		return
+	}
+
+	dy := (page+1)*config.PageHeight + config.MarginTop - b.block.Top
+	b.block.shift(dy)
+	b.currentY += dy
+}
+
+// cover replaces the characters the measurer's fonts cannot draw
+func (b *layoutBuilder) cover(text string, style FontStyle) string {
+	if checker, ok := b.measurer.(GlyphChecker); ok {
+		return coverText(text, style, checker, b.config.MissingGlyph)
+	}
+	return text
+}
+
+// text places a left-aligned text run
+func (b *layoutBuilder) text(x, y float64, text string, style FontStyle, size float64) {
+	b.block.Texts = append(b.block.Texts, TextRun{X: x, Y: y, Text: b.cover(text, style), Style: style, Size: size})
+}
+
+// measure returns the width of a text, measuring each text and font only once
+func (b *layoutBuilder) measure(text string, style FontStyle, size float64) float64 {
+	key := measureKey{text: text, style: style, size: size}
+	if width, ok := b.widths[key]; ok {
+		return width
+	}
+	width := b.measurer.MeasureText(b.cover(text, style), style, size)
+	b.widths[key] = width
+	return width
+}
+
+// textRight places a text run that ends at x
+func (b *layoutBuilder) textRight(x, y float64, text string, style FontStyle, size float64) {
+	width := b.measure(text, style, size)
+	b.block.Texts = append(b.block.Texts, TextRun{X: x - width, Y: y, Text: b.cover(text, style), Style: style, Size: size, Align: AlignRight})
+}
+
+// textCenter places a text run centered on the page
+func (b *layoutBuilder) textCenter(y float64, text string, style FontStyle, size float64) {
+	width := b.measure(text, style, size)
+	b.block.Texts = append(b.block.Texts, TextRun{X: (b.config.PageWidth - width) / 2, Y: y, Text: b.cover(text, style), Style: style, Size: size, Align: AlignCenter})
+}
+
+// wrap splits text into lines that fit maxWidth in the given font
+func (b *layoutBuilder) wrap(text string, style FontStyle, size, maxWidth float64) []string {
+	return wrapLines(func(s string) float64 {
+		return b.measure(s, style, size)
+	}, b.cover(text, style), maxWidth, b.config.Hyphenate)
+}
+
+// wrapSpans splits styled text into lines that fit maxWidth at the given size
+func (b *layoutBuilder) wrapSpans(spans []textSpan, size, maxWidth float64) [][]textSpan {
+	covered := make([]textSpan, len(spans))
+	for i, span := range spans {
+		covered[i] = textSpan{Text: b.cover(span.Text, span.Style), Style: span.Style}
+	}
+	return wrapSpans(func(s string, style FontStyle) float64 {
+		return b.measure(s, style, size)
+	}, covered, maxWidth, b.config.Hyphenate)
+}
+
+// textSpans places the spans of a line one after the other, starting at x
+func (b *layoutBuilder) textSpans(x, y float64, spans []textSpan, size float64) {
+	for _, span := range spans {
+		b.text(x, y, span.Text, span.Style, size)
+		x += b.measure(span.Text, span.Style, size)
+	}
+}
+
+// blockFont returns the font of a template block: its own size, weight and slant when
+// set, the typography role of its type otherwise
+func (b *layoutBuilder) blockFont(block TemplateBlock) (FontStyle, float64) {
+	role := b.typography.Body
+	switch block.Type {
+	case "heading":
+		role = b.typography.Name
+	case "id":
+		role = b.typography.Meta
+	}
+
+	if block.Size > 0 {
+		role.Size = block.Size * b.typography.Scale
+	}
+	if block.Bold != nil {
+		role.Bold = *block.Bold
+	}
+	if block.Italic != nil {
+		role.Italic = *block.Italic
+	}
+	return role.Style(), role.Size
+}
+
+// header places the logo, the zone name and the details of the round
+func (b *layoutBuilder) header(manifest *Manifest) {
+	config := b.config
+	header := b.typography.Header
+	b.startBlock(BlockHeader)
+
+	logoPath := "assets/logo.png"
+	if _, err := os.Stat(logoPath); err == nil {
+		b.block.Images = append(b.block.Images, Image{Path: logoPath, X: (config.PageWidth - 20) / 2, Y: b.currentY, W: 18, H: 16})
+		b.currentY += 12
+	}
+
+	// Calculate available width for zone text
+	availableWidth := config.PageWidth - config.MarginLeft - config.MarginRight - b.sp(4)
+	for _, line := range b.wrap(manifest.Zone, header.Style(), header.Size, availableWidth) {
+		b.text(config.MarginLeft+b.sp(2), b.currentY, line, header.Style(), header.Size)
+		b.currentY += config.LineHeight + b.sp(1.0)
+	}
+	b.currentY += config.ZoneSpacing
+
+	// Date, courier, shop and notes in the body text, each wrapped on its own lines
+	body := b.typography.Body
+	top := b.currentY
+	for _, detail := range manifest.headerLines(&config.Labels) {
+		for _, line := range b.wrap(detail, body.Style(), body.Size, availableWidth) {
+			b.text(config.MarginLeft+b.sp(2), b.currentY, line, body.Style(), body.Size)
+			b.currentY += config.LineHeight + b.sp(1.0)
+		}
+	}
+	for _, note := range strings.Split(manifest.Notes, "\n") {
+		if strings.TrimSpace(note) == "" {
+			continue
+		}
+		for _, line := range b.wrapSpans(parseEmphasis(note, body.Style()), body.Size, availableWidth) {
+			b.textSpans(config.MarginLeft+b.sp(2), b.currentY, line, body.Size)
+			b.currentY += config.LineHeight + b.sp(1.0)
+		}
+	}
+	if b.currentY > top {
+		b.currentY += config.ZoneSpacing
+	}
+
+	b.endBlock()
+}
+
+// entry places the template sections of a single delivery, followed by a separator
+// unless it is the last one
+func (b *layoutBuilder) entry(entry DeliveryEntry, last bool) {
+	config := b.config
+	b.startBlock(BlockEntry)
+
+	for _, block := range b.template.Blocks {
+		switch block.Type {
+		case "heading":
+			b.entryHeading(entry, block)
+		case "id":
+			b.entryID(entry, block)
+		case "address":
+			b.entryAddress(entry, block)
+		case "phone":
+			b.entryPhone(entry, block)
+		case "items":
+			b.entryItems(entry, block)
+		case "notes":
+			b.entryNotes(entry, block)
+		case "box":
+			b.entryBox(block)
+		}
+	}
+
+	if !last {
+		b.currentY += config.EntrySpacing / 2
+		b.block.Lines = append(b.block.Lines, Line{
+			X1:    config.MarginLeft,
+			Y1:    b.currentY,
+			X2:    config.PageWidth - config.MarginRight,
+			Y2:    b.currentY,
+			Width: 0.3,
+		})
+		b.currentY += config.EntrySpacing / 2
+	}
+
+	b.endBlock()
+}
+
+// captionOffset returns how far from the margin the text after a caption starts:
+// minimum, or further when a custom caption is wider
+func (b *layoutBuilder) captionOffset(caption string, style FontStyle, size, minimum float64) float64 {
+	width := b.measure(caption, style, size) + b.sp(1)
+	if width > minimum {
+		return width
+	}
+	return minimum
+}
+
+// entryHeading places the customer name with the total on the right. A name that would
+// run into the total is shrunk a little, or wrapped when shrinking is not enough.
+func (b *layoutBuilder) entryHeading(entry DeliveryEntry, block TemplateBlock) {
+	config := b.config
+	style, size := b.blockFont(block)
+	right := config.PageWidth - config.MarginRight
+	totalText := FormatAmount(entry.CalculateTotal(), &config.Labels)
+	b.textRight(right, b.currentY, totalText, style, size)
+
+	nameWidth := right - config.MarginLeft - b.measure(totalText, style, size) - b.sp(2)
+	lines := []string{entry.Name}
+	nameSize := size
+	if width := b.measure(entry.Name, style, size); width > nameWidth {
+		// Shrinking down to 80% keeps the name readable, below that wrapping reads better
+		shrunk := size * nameWidth / width
+		if shrunk >= size*0.8 && b.measure(entry.Name, style, shrunk) <= nameWidth {
+			nameSize = shrunk
+		} else {
+			lines = b.wrapName(entry.Name, style, size, nameWidth)
+		}
+	}
+
+	for i, line := range lines {
+		if i > 0 {
+			b.currentY += config.LineHeight + b.sp(2.0)
+		}
+		if line != "" {
+			b.text(config.MarginLeft, b.currentY, line, style, nameSize)
+		}
+	}
+	b.currentY += config.LineHeight + b.sp(2.0)
+}
+
+// wrapName splits a customer name into lines: the first one shares its row with the
+// total and holds the words that fit firstWidth, the others use the full content width.
+// The first line is empty when not even the first word fits next to the total.
+func (b *layoutBuilder) wrapName(name string, style FontStyle, size, firstWidth float64) []string {
+	config := b.config
+	words := strings.Fields(name)
+	fit := 0
+	for fit < len(words) && b.measure(strings.Join(words[:fit+1], " "), style, size) <= firstWidth {
+		fit++
+	}
+
+	lines := []string{strings.Join(words[:fit], " ")}
+	if fit < len(words) {
+		contentWidth := config.PageWidth - config.MarginLeft - config.MarginRight
+		lines = append(lines, b.wrap(strings.Join(words[fit:], " "), style, size, contentWidth)...)
+	}
+	return lines
+}
+
+// entryID places the customer ID
+func (b *layoutBuilder) entryID(entry DeliveryEntry, block TemplateBlock) {
+	config := b.config
+	style, size := b.blockFont(block)
+	labels := config.Labels
+	labels.ID = block.label(labels.ID)
+	b.text(config.MarginLeft, b.currentY, FormatID(entry.ID, &labels), style, size)
+	b.currentY += config.LineHeight + config.NameSpacing
+}
+
+// entryAddress places the wrapped address after its icon
+func (b *layoutBuilder) entryAddress(entry DeliveryEntry, block TemplateBlock) {
+	config := b.config
+	style, size := b.blockFont(block)
+	icon := block.label(">")
+	offset := b.captionOffset(icon, style, size, b.sp(4))
+	addressLines := b.wrap(entry.Address, style, size, config.PageWidth-config.MarginLeft-config.MarginRight-offset-b.sp(3)) // -3 for the continuation indent
+	for i, line := range addressLines {
+		if i == 0 {
+			b.text(config.MarginLeft, b.currentY, icon, style, size)
+			b.text(config.MarginLeft+offset, b.currentY, line, style, size)
+		} else {
+			b.text(config.MarginLeft+offset+b.sp(2), b.currentY, line, style, size)
+		}
+		b.currentY += config.LineHeight + b.sp(0.5)
+	}
+	b.currentY += config.AddressSpacing
+}
+
+// entryPhone places the phone number after its icon
+func (b *layoutBuilder) entryPhone(entry DeliveryEntry, block TemplateBlock) {
+	config := b.config
+	style, size := b.blockFont(block)
+	icon := block.label("#")
+	offset := b.captionOffset(icon, style, size, b.sp(4))
+	b.text(config.MarginLeft, b.currentY, icon, style, size)
+	if entry.Phone == "" {
+		b.text(config.MarginLeft+offset, b.currentY, config.Labels.NoPhone, style, size)
+	} else {
+		b.text(config.MarginLeft+offset, b.currentY, entry.Phone, style, size)
+	}
+	b.currentY += config.LineHeight + config.PhoneSpacing
+}
+
+// entryItems places the caption and the item prices in as many columns as the page
+// width allows. Cells are at least ItemWidth wide, labels wider than a cell span the
+// next cells and labels wider than the page wrap.
+func (b *layoutBuilder) entryItems(entry DeliveryEntry, block TemplateBlock) {
+	config := b.config
+	style, size := b.blockFont(block)
+	b.text(config.MarginLeft, b.currentY, block.label(config.Labels.Items), style, size)
+	b.currentY += config.LineHeight + config.ItemSpacing
+
+	var items []string
+	var widths []float64
+	for _, price := range strings.Split(entry.Items, "+") {
+		if item, ok := FormatItem(price, &config.Labels); ok {
+			items = append(items, "• "+item)
+			widths = append(widths, b.measure("• "+item, style, size))
+		}
+	}
+	if len(items) == 0 {
+		return
+	}
+
+	// Widen the cells to the widest label, unless it is so wide it should rather span cells
+	contentWidth := config.PageWidth - config.MarginLeft - config.MarginRight
+	gap := b.sp(2)
+	cellWidth := config.ItemWidth
+	for _, width := range widths {
+		if width+gap <= contentWidth/2 && width+gap > cellWidth {
+			cellWidth = width + gap
+		}
+	}
+	columns := int(contentWidth / cellWidth)
+	if columns < 1 {
+		columns = 1
+	}
+
+	column := 0
+	for i, item := range items {
+		span := int(math.Ceil((widths[i] + gap) / cellWidth))
+		if column > 0 && column+span > columns {
+			b.currentY += config.LineHeight + config.ItemSpacing
+			column = 0
+		}
+
+		if span <= columns {
+			b.text(config.MarginLeft+cellWidth*float64(column), b.currentY, item, style, size)
+			column += span
+			continue
+		}
+
+		// Too wide for the page: wrap it on rows of its own, aligned after the bullet
+		indent := b.measure("• ", style, size)
+		for j, line := range b.wrap(strings.TrimPrefix(item, "• "), style, size, contentWidth-indent) {
+			if j == 0 {
+				b.text(config.MarginLeft, b.currentY, "• "+line, style, size)
+				continue
+			}
+			b.currentY += config.LineHeight + config.ItemSpacing
+			b.text(config.MarginLeft+indent, b.currentY, line, style, size)
+		}
+		column = columns
+	}
+	b.currentY += config.LineHeight + config.ItemSpacing
+}
+
+// entryNotes places the customer's notes when there are any, with the words marked
+// up as *bold* or _italic_ in those styles
+func (b *layoutBuilder) entryNotes(entry DeliveryEntry, block TemplateBlock) {
+	if entry.Notes == "" {
+		return
+	}
+
+	config := b.config
+	style, size := b.blockFont(block)
+	b.currentY += config.SectionSpacing
+	caption := block.label(config.Labels.Notes)
+	offset := b.captionOffset(caption, style, size, b.sp(12))
+	b.text(config.MarginLeft, b.currentY, caption, style, size)
+
+	// Calculate available width for notes text
+	notesWidth := config.PageWidth - config.MarginLeft - config.MarginRight - offset
+	for _, line := range b.wrapSpans(parseEmphasis(entry.Notes, FontRegular), size, notesWidth) {
+		b.textSpans(config.MarginLeft+offset, b.currentY, line, size)
+		b.currentY += config.LineHeight + b.sp(0.5)
+	}
+}
+
+// entryBox places the dashed note-taking box for the deliverer
+func (b *layoutBuilder) entryBox(block TemplateBlock) {
+	config := b.config
+	style, size := b.blockFont(block)
+	b.currentY += config.SectionSpacing
+	b.block.Boxes = append(b.block.Boxes, Box{
+		X:         config.MarginLeft,
+		Y:         b.currentY,
+		W:         config.PageWidth - config.MarginLeft - config.MarginRight,
+		H:         config.NoteBoxHeight,
+		LineWidth: 0.1,
+		Dashed:    true,
+	})
+	b.text(config.MarginLeft+b.sp(1), b.currentY+b.sp(2), block.label(config.Labels.DelivererNotes), style, size)
+	b.currentY += config.NoteBoxHeight
+}
+
+// footer places the date of the round, the quote and the slogan
+func (b *layoutBuilder) footer(date time.Time) {
+	config := b.config
+	footer := b.typography.Footer
+	slogan := b.typography.Slogan
+	b.startBlock(BlockFooter)
+
+	// Add the date of the round
+	b.currentY += config.DateSpacing
+	b.textCenter(b.currentY, date.Format("02/01/2006"), footer.Style(), footer.Size)
+
+	// The quote is set in italics, and wrapped so narrow rolls do not cut it off
+	quote := footer
+	quote.Italic = true
+	contentWidth := config.PageWidth - config.MarginLeft - config.MarginRight
+	for _, line := range b.wrap(config.Labels.Quote, quote.Style(), quote.Size, contentWidth) {
+		b.currentY += config.LineHeight + b.sp(2.0)
+		b.textCenter(b.currentY, line, quote.Style(), quote.Size)
+	}
+
+	// Add the final word to this non-sense
+	b.currentY += config.LineHeight + b.sp(2.0)
+	b.textCenter(b.currentY, config.Labels.Slogan, slogan.Style(), slogan.Size)
+	b.currentY += config.LineHeight + b.sp(2.0)
+
+	b.endBlock()
+}
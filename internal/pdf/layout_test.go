@@ -0,0 +1,98 @@
+package pdf
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// fixedMeasurer measures every character as a fixed fraction of the font size, so
+// layouts can be checked without loading fonts
+type fixedMeasurer struct{}
+
+func (fixedMeasurer) MeasureText(text string, style FontStyle, size float64) float64 {
+	width := 0.18
+	if style.Bold() {
+		width = 0.2
+	}
+	return float64(utf8.RuneCountInString(text)) * size * width
+}
+
+// headingRuns returns the total and the name lines laid out for the single entry of doc
+func headingRuns(t *testing.T, doc *Document) (TextRun, []TextRun) {
+	t.Helper()
+	for _, block := range doc.Blocks {
+		if block.Kind != BlockEntry {
+			continue
+		}
+		// The total is placed first, then the name lines until the ID
+		total := block.Texts[0]
+		var names []TextRun
+		for _, run := range block.Texts[1:] {
+			if strings.HasPrefix(run.Text, "ID:") {
+				break
+			}
+			names = append(names, run)
+		}
+		return total, names
+	}
+	t.Fatal("no entry block in the layout")
+	return TextRun{}, nil
+}
+
+func TestLongNamesStayClearOfTheTotal(t *testing.T) {
+	names := []string{
+		"Randrianantenaina Andriamahefa",
+		"Razafindrakotohasina Tsiory Fanomezantsoa",
+		"Rakotondrabe Andrianjafy Ramanantsoa Harimalala",
+		"Andrianarivelo-Razafimahatratra",
+	}
+	measurer := fixedMeasurer{}
+
+	for _, paper := range []string{"58mm", "78mm"} {
+		for _, scale := range []float64{1, 1.25, 1.5} {
+			for _, name := range names {
+				config, err := PaperPreset(paper)
+				if err != nil {
+					t.Fatal(err)
+				}
+				config.Typography.Scale = scale
+				manifest := &Manifest{
+					Zone:    "Analakely",
+					Entries: []DeliveryEntry{{ID: "001", Name: name, Items: "125+300"}},
+				}
+
+				doc := BuildLayout(manifest, config, measurer)
+				total, lines := headingRuns(t, doc)
+				if total.Align != AlignRight {
+					t.Fatalf("%s at %v%%, %q: first run %q is not the total", paper, scale*100, name, total.Text)
+				}
+
+				contentRight := config.PageWidth - config.MarginRight
+				laidOut := ""
+				for _, line := range lines {
+					end := line.X + measurer.MeasureText(line.Text, line.Style, line.Size)
+					if line.Y == total.Y && end > total.X {
+						t.Errorf("%s at %v%%: %q ends at %.1fmm, into the total at %.1fmm", paper, scale*100, line.Text, end, total.X)
+					}
+					if end > contentRight+1e-9 {
+						t.Errorf("%s at %v%%: %q ends at %.1fmm, past the margin at %.1fmm", paper, scale*100, line.Text, end, contentRight)
+					}
+
+					// Lines only break between words or after a hyphen
+					switch {
+					case laidOut == "":
+						laidOut = line.Text
+					case strings.HasSuffix(laidOut, "-"):
+						laidOut += line.Text
+					default:
+						laidOut += " " + line.Text
+					}
+				}
+				if laidOut != name {
+					t.Errorf("%s at %v%%: name laid out as %q, want %q", paper, scale*100, laidOut, name)
+				}
+			}
+		}
+	}
+}
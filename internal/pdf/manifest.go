@@ -0,0 +1,204 @@
+package pdf
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Manifest is the document a courier takes on a round: the zone, who delivers it for
+// which shop, and its deliveries
+type Manifest struct {
+	Zone         string
+	Date         time.Time // Day of the round, today when zero
+	Courier      string
+	CourierPhone string
+	Shop         string
+	ShopContact  string
+	Notes        string // Free-form notes printed under the zone, with *bold* and _italic_ markup
+	Entries      []DeliveryEntry
+}
+
+// dateFormats are the layouts accepted for the date of a manifest
+var dateFormats = []string{"02/01/2006", "2006-01-02"}
+
+// ParseManifest parses pasted content into a manifest. Lines without a tab before the
+// first entry are "key: value" header lines setting the zone, date, courier,
+// courier_phone, shop, shop_contact or note; the other lines are entries as read by
+// ParseContent. Lines with an unknown key are skipped and returned as warnings.
+func ParseManifest(content string) (*Manifest, []string, error) {
+	manifest := &Manifest{}
+	var warnings []string
+
+	lines := strings.Split(content, "\n")
+	start := len(lines)
+	for i, line := range lines {
+		if strings.Contains(line, "\t") {
+			start = i
+			break
+		}
+
+		key, value, ok := headerLine(line)
+		if !ok {
+			continue
+		}
+		known, err := manifest.setHeader(key, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		if !known {
+			warnings = append(warnings, fmt.Sprintf("line %d: unknown header %q, skipped", i+1, key))
+		}
+	}
+
+	manifest.Entries = ParseContent(strings.Join(lines[start:], "\n"))
+	return manifest, warnings, nil
+}
+
+// ParseBatch parses pasted content holding the lists of several zones into one manifest
+// per zone, in the order the zones first appear. A "zone: name" line starts the section
+// of a zone, and an entry with a seventh column goes to the zone it names. Header lines
+// before the first zone apply to every zone, the ones after it to that zone only.
+// Entries found before any zone are gathered in a manifest without a zone. As in
+// ParseManifest, lines with an unknown key are skipped and returned as warnings.
+func ParseBatch(content string) ([]*Manifest, []string, error) {
+	shared := &Manifest{}
+	var manifests []*Manifest
+	var warnings []string
+	zoneManifest := func(zone string) *Manifest {
+		for _, manifest := range manifests {
+			if strings.EqualFold(manifest.Zone, zone) {
+				return manifest
+			}
+		}
+		manifest := *shared
+		manifest.Zone = zone
+		manifests = append(manifests, &manifest)
+		return &manifest
+	}
+
+	var current *Manifest
+	for i, line := range strings.Split(content, "\n") {
+		if strings.Contains(line, "\t") {
+			for _, entry := range ParseContent(line) {
+				target := current
+				if zone := strings.TrimSpace(entry.Zone); zone != "" {
+					target = zoneManifest(zone)
+				} else if target == nil {
+					target = zoneManifest("")
+				}
+				target.Entries = append(target.Entries, entry)
+			}
+			continue
+		}
+
+		key, value, ok := headerLine(line)
+		if !ok {
+			continue
+		}
+		if key == "zone" {
+			current = zoneManifest(value)
+			continue
+		}
+		target := shared
+		if current != nil {
+			target = current
+		}
+		known, err := target.setHeader(key, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		if !known {
+			warnings = append(warnings, fmt.Sprintf("line %d: unknown header %q, skipped", i+1, key))
+		}
+	}
+
+	return manifests, warnings, nil
+}
+
+// headerLine splits a "key: value" header line. Stray lines without a key were always
+// skipped, so they are not reported.
+func headerLine(line string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(line, ":")
+	if !ok {
+		return "", "", false
+	}
+	return strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value), true
+}
+
+// setHeader sets the field of the manifest named by a header line, notes adding up. It
+// reports whether the key names a field, so a title line such as "Lisitra androany: 12"
+// can be skipped.
+func (m *Manifest) setHeader(key, value string) (bool, error) {
+	switch key {
+	case "zone":
+		m.Zone = value
+	case "date":
+		date, err := ParseDate(value)
+		if err != nil {
+			return true, err
+		}
+		m.Date = date
+	case "courier":
+		m.Courier = value
+	case "courier_phone":
+		m.CourierPhone = value
+	case "shop":
+		m.Shop = value
+	case "shop_contact":
+		m.ShopContact = value
+	case "note", "notes":
+		if m.Notes != "" {
+			m.Notes += "\n"
+		}
+		m.Notes += value
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// ParseDate reads a manifest date written as 31/12/2025 or 2025-12-31
+func ParseDate(value string) (time.Time, error) {
+	for _, layout := range dateFormats {
+		if date, err := time.ParseInLocation(layout, strings.TrimSpace(value), time.Local); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected DD/MM/YYYY", value)
+}
+
+// Day returns the day of the round, today when the manifest has no date
+func (m *Manifest) Day() time.Time {
+	if m.Date.IsZero() {
+		return time.Now()
+	}
+	return m.Date
+}
+
+// headerLines returns the captioned date, courier and shop lines printed under the
+// zone, leaving out the ones that are not set
+func (m *Manifest) headerLines(labels *Labels) []string {
+	var lines []string
+	if !m.Date.IsZero() {
+		lines = append(lines, fmt.Sprintf("%s %s", labels.Date, m.Date.Format("02/01/2006")))
+	}
+	if who := joinDetails(m.Courier, m.CourierPhone); who != "" {
+		lines = append(lines, fmt.Sprintf("%s %s", labels.Courier, who))
+	}
+	if shop := joinDetails(m.Shop, m.ShopContact); shop != "" {
+		lines = append(lines, fmt.Sprintf("%s %s", labels.Shop, shop))
+	}
+	return lines
+}
+
+// joinDetails joins a name and its contact, leaving out the empty ones
+func joinDetails(name, contact string) string {
+	var parts []string
+	for _, part := range []string{name, contact} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,36 @@
+package pdf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnknownHeadersAreSkipped(t *testing.T) {
+	content := "Lisitra androany: 12\nzone: Analakely\ncourier: Rado\n001\tRakoto\tLot II A 12\t0341234567\t12+3\t"
+
+	manifest, warnings, err := ParseManifest(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Zone != "Analakely" || manifest.Courier != "Rado" || len(manifest.Entries) != 1 {
+		t.Errorf("manifest read as zone %q, courier %q, %d entries", manifest.Zone, manifest.Courier, len(manifest.Entries))
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], `line 1: unknown header "lisitra androany"`) {
+		t.Errorf("warnings %q, want the title line reported", warnings)
+	}
+
+	manifests, warnings, err := ParseBatch(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifests) != 1 || manifests[0].Zone != "Analakely" || len(manifests[0].Entries) != 1 {
+		t.Errorf("batch read as %d manifests", len(manifests))
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], `line 1: unknown header "lisitra androany"`) {
+		t.Errorf("batch warnings %q, want the title line reported", warnings)
+	}
+
+	if _, _, err := ParseManifest("date: 31/02/2026"); err == nil {
+		t.Error("an invalid date was accepted")
+	}
+}
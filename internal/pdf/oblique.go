@@ -0,0 +1,313 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// obliqueSlant is how far synthesized oblique outlines lean right per unit of height,
+// about 12 degrees like most italics
+const obliqueSlant = 0.21
+
+// Flags of the points of simple glyphs and of the components of composite glyphs
+const (
+	pointOnCurve   = 0x01
+	pointXShort    = 0x02
+	pointYShort    = 0x04
+	pointRepeat    = 0x08
+	pointXSame     = 0x10
+	pointYSame     = 0x20
+	argsAreWords   = 0x0001
+	argsAreXY      = 0x0002
+	haveScale      = 0x0008
+	moreComponents = 0x0020
+	haveXYScale    = 0x0040
+	haveTwoByTwo   = 0x0080
+	haveCompInstr  = 0x0100
+)
+
+// obliqueFont returns a copy of a TrueType font with its outlines slanted, standing in
+// for the italic face of a family that has none. Hinting instructions are dropped as
+// they no longer match the outlines.
+func obliqueFont(data []byte) ([]byte, error) {
+	version, tables, err := readFontTables(data, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	head := findFontTable(tables, "head")
+	maxp := findFontTable(tables, "maxp")
+	loca := findFontTable(tables, "loca")
+	glyf := findFontTable(tables, "glyf")
+	if len(head) < 54 || len(maxp) < 6 || loca == nil || glyf == nil {
+		return nil, fmt.Errorf("font has no TrueType outlines")
+	}
+
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	longOffsets := binary.BigEndian.Uint16(head[50:]) == 1
+	offset := func(i int) int {
+		if longOffsets {
+			return int(binary.BigEndian.Uint32(loca[4*i:]))
+		}
+		return 2 * int(binary.BigEndian.Uint16(loca[2*i:]))
+	}
+	if (longOffsets && len(loca) < 4*(numGlyphs+1)) || (!longOffsets && len(loca) < 2*(numGlyphs+1)) {
+		return nil, fmt.Errorf("font glyph locations are truncated")
+	}
+
+	// Slant every glyph and index the new table with long offsets
+	var newGlyf bytes.Buffer
+	newLoca := make([]byte, 4*(numGlyphs+1))
+	bounds := [4]int{math.MaxInt16, math.MaxInt16, math.MinInt16, math.MinInt16}
+	for i := 0; i < numGlyphs; i++ {
+		binary.BigEndian.PutUint32(newLoca[4*i:], uint32(newGlyf.Len()))
+		start, end := offset(i), offset(i+1)
+		if start >= end {
+			continue
+		}
+		if end > len(glyf) {
+			return nil, fmt.Errorf("glyph %d is out of the font", i)
+		}
+
+		glyph, box, err := slantGlyph(glyf[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("glyph %d: %v", i, err)
+		}
+		newGlyf.Write(glyph)
+		for newGlyf.Len()%4 != 0 {
+			newGlyf.WriteByte(0)
+		}
+		bounds = [4]int{min(bounds[0], box[0]), min(bounds[1], box[1]), max(bounds[2], box[2]), max(bounds[3], box[3])}
+	}
+	binary.BigEndian.PutUint32(newLoca[4*numGlyphs:], uint32(newGlyf.Len()))
+
+	newHead := bytes.Clone(head)
+	for i, value := range bounds {
+		binary.BigEndian.PutUint16(newHead[36+2*i:], uint16(int16(value)))
+	}
+	binary.BigEndian.PutUint16(newHead[44:], binary.BigEndian.Uint16(head[44:])|0x02) // Italic in macStyle
+	binary.BigEndian.PutUint16(newHead[50:], 1)
+
+	// The italic angle is counted counterclockwise, in 16.16 fixed point
+	var newPost []byte
+	if post := findFontTable(tables, "post"); len(post) >= 8 {
+		newPost = bytes.Clone(post)
+		angle := -math.Atan(obliqueSlant) * 180 / math.Pi
+		binary.BigEndian.PutUint32(newPost[4:], uint32(int32(angle*65536)))
+	}
+
+	slanted := make([]fontTable, 0, len(tables))
+	for _, table := range tables {
+		switch table.tag {
+		case "head":
+			table.data = newHead
+		case "loca":
+			table.data = newLoca
+		case "glyf":
+			table.data = newGlyf.Bytes()
+		case "post":
+			if newPost != nil {
+				table.data = newPost
+			}
+		}
+		slanted = append(slanted, table)
+	}
+	return writeFont(version, slanted), nil
+}
+
+// slant moves a point right in proportion to its height
+func slant(x, y int) int {
+	return x + int(math.Round(float64(y)*obliqueSlant))
+}
+
+// slantGlyph returns a glyph description with its points slanted, and its new bounds
+func slantGlyph(glyph []byte) ([]byte, [4]int, error) {
+	if len(glyph) < 10 {
+		return nil, [4]int{}, fmt.Errorf("glyph header is truncated")
+	}
+	contours := int(int16(binary.BigEndian.Uint16(glyph)))
+	if contours < 0 {
+		return slantComposite(glyph)
+	}
+	return slantSimple(glyph, contours)
+}
+
+// slantSimple slants the points of a glyph made of contours
+func slantSimple(glyph []byte, contours int) ([]byte, [4]int, error) {
+	p := 10
+	if len(glyph) < p+2*contours+2 {
+		return nil, [4]int{}, fmt.Errorf("glyph contours are truncated")
+	}
+	endPoints := glyph[p : p+2*contours]
+	points := 0
+	if contours > 0 {
+		points = int(binary.BigEndian.Uint16(endPoints[2*contours-2:])) + 1
+	}
+	p += 2*contours + 2 + int(binary.BigEndian.Uint16(glyph[p+2*contours:]))
+
+	// Expand the repeated flags, then read the coordinates as deltas
+	flags := make([]byte, 0, points)
+	for len(flags) < points {
+		if p >= len(glyph) {
+			return nil, [4]int{}, fmt.Errorf("glyph flags are truncated")
+		}
+		flag := glyph[p]
+		p++
+		flags = append(flags, flag)
+		if flag&pointRepeat != 0 && p < len(glyph) {
+			for n := int(glyph[p]); n > 0 && len(flags) < points; n-- {
+				flags = append(flags, flag)
+			}
+			p++
+		}
+	}
+
+	readCoordinates := func(short, same byte) ([]int, error) {
+		values := make([]int, points)
+		value := 0
+		for i, flag := range flags {
+			switch {
+			case flag&short != 0:
+				if p >= len(glyph) {
+					return nil, fmt.Errorf("glyph coordinates are truncated")
+				}
+				delta := int(glyph[p])
+				if flag&same == 0 {
+					delta = -delta
+				}
+				value += delta
+				p++
+			case flag&same == 0:
+				if p+2 > len(glyph) {
+					return nil, fmt.Errorf("glyph coordinates are truncated")
+				}
+				value += int(int16(binary.BigEndian.Uint16(glyph[p:])))
+				p += 2
+			}
+			values[i] = value
+		}
+		return values, nil
+	}
+	xs, err := readCoordinates(pointXShort, pointXSame)
+	if err != nil {
+		return nil, [4]int{}, err
+	}
+	ys, err := readCoordinates(pointYShort, pointYSame)
+	if err != nil {
+		return nil, [4]int{}, err
+	}
+
+	box := [4]int{math.MaxInt16, math.MaxInt16, math.MinInt16, math.MinInt16}
+	for i := range xs {
+		xs[i] = slant(xs[i], ys[i])
+		box = [4]int{min(box[0], xs[i]), min(box[1], ys[i]), max(box[2], xs[i]), max(box[3], ys[i])}
+	}
+	if points == 0 {
+		box = [4]int{}
+	}
+
+	// Write the glyph back without instructions, with one flag per point
+	var out bytes.Buffer
+	binary.Write(&out, binary.BigEndian, []int16{int16(contours), int16(box[0]), int16(box[1]), int16(box[2]), int16(box[3])})
+	out.Write(endPoints)
+	out.Write([]byte{0, 0})
+	var xData, yData bytes.Buffer
+	writeDelta := func(buf *bytes.Buffer, delta int, short, same byte) byte {
+		switch {
+		case delta == 0:
+			return same
+		case delta > -256 && delta < 256:
+			if delta > 0 {
+				buf.WriteByte(byte(delta))
+				return short | same
+			}
+			buf.WriteByte(byte(-delta))
+			return short
+		}
+		binary.Write(buf, binary.BigEndian, int16(delta))
+		return 0
+	}
+	lastX, lastY := 0, 0
+	for i, flag := range flags {
+		newFlag := flag & pointOnCurve
+		newFlag |= writeDelta(&xData, xs[i]-lastX, pointXShort, pointXSame)
+		newFlag |= writeDelta(&yData, ys[i]-lastY, pointYShort, pointYSame)
+		out.WriteByte(newFlag)
+		lastX, lastY = xs[i], ys[i]
+	}
+	out.Write(xData.Bytes())
+	out.Write(yData.Bytes())
+	return out.Bytes(), box, nil
+}
+
+// slantComposite slants the offsets of the components of a glyph built from other
+// glyphs, whose own outlines are slanted separately. The bounds are those of the
+// original box once slanted.
+func slantComposite(glyph []byte) ([]byte, [4]int, error) {
+	xMin := int(int16(binary.BigEndian.Uint16(glyph[2:])))
+	yMin := int(int16(binary.BigEndian.Uint16(glyph[4:])))
+	xMax := int(int16(binary.BigEndian.Uint16(glyph[6:])))
+	yMax := int(int16(binary.BigEndian.Uint16(glyph[8:])))
+	box := [4]int{slant(xMin, min(yMin, 0)), yMin, slant(xMax, max(yMax, 0)), yMax}
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.BigEndian, []int16{-1, int16(box[0]), int16(box[1]), int16(box[2]), int16(box[3])})
+	p := 10
+	for {
+		if p+4 > len(glyph) {
+			return nil, [4]int{}, fmt.Errorf("glyph components are truncated")
+		}
+		flags := binary.BigEndian.Uint16(glyph[p:])
+		component := binary.BigEndian.Uint16(glyph[p+2:])
+		p += 4
+
+		var dx, dy int
+		argsSize := 2
+		if flags&argsAreWords != 0 {
+			argsSize = 4
+		}
+		if p+argsSize > len(glyph) {
+			return nil, [4]int{}, fmt.Errorf("glyph components are truncated")
+		}
+		if flags&argsAreWords != 0 {
+			dx, dy = int(int16(binary.BigEndian.Uint16(glyph[p:]))), int(int16(binary.BigEndian.Uint16(glyph[p+2:])))
+		} else if flags&argsAreXY != 0 {
+			dx, dy = int(int8(glyph[p])), int(int8(glyph[p+1]))
+		} else {
+			dx, dy = int(glyph[p]), int(glyph[p+1])
+		}
+		p += argsSize
+
+		transformSize := 0
+		switch {
+		case flags&haveScale != 0:
+			transformSize = 2
+		case flags&haveXYScale != 0:
+			transformSize = 4
+		case flags&haveTwoByTwo != 0:
+			transformSize = 8
+		}
+		if p+transformSize > len(glyph) {
+			return nil, [4]int{}, fmt.Errorf("glyph components are truncated")
+		}
+
+		// Offsets are written as words so the slanted values always fit; point numbers
+		// used to anchor a component are kept as they are
+		newFlags := flags &^ haveCompInstr
+		if flags&argsAreXY != 0 {
+			newFlags |= argsAreWords
+			binary.Write(&out, binary.BigEndian, []uint16{newFlags, component, uint16(int16(slant(dx, dy))), uint16(int16(dy))})
+		} else {
+			binary.Write(&out, binary.BigEndian, []uint16{newFlags, component})
+			out.Write(glyph[p-argsSize : p])
+		}
+		out.Write(glyph[p : p+transformSize])
+		p += transformSize
+
+		if flags&moreComponents == 0 {
+			return out.Bytes(), box, nil
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestObliqueFont(t *testing.T) {
+	data, err := obliqueFont(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slanted, err := sfnt.Parse(data)
+	if err != nil {
+		t.Fatalf("slanted font does not parse: %v", err)
+	}
+	upright, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadPDFFont(t, data)
+
+	if post := slanted.PostTable(); math.Abs(post.ItalicAngle+11.86) > 0.01 {
+		t.Errorf("italic angle %v, want -11.86", post.ItalicAngle)
+	}
+
+	// Every point moves right by 0.21 of its height, within the rounding to font units,
+	// which midpoints implied between two off-curve points add up. Loaded at one pixel
+	// per unit, sfnt returns coordinates in 1/64 unit, y down.
+	ppem := fixed.I(int(upright.UnitsPerEm()))
+	var uprightBuf, slantedBuf sfnt.Buffer
+	for i := 0; i < upright.NumGlyphs(); i++ {
+		glyph := sfnt.GlyphIndex(i)
+		want, err := upright.LoadGlyph(&uprightBuf, glyph, ppem, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := slanted.LoadGlyph(&slantedBuf, glyph, ppem, nil)
+		if err != nil {
+			t.Fatalf("glyph %d: %v", i, err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("glyph %d has %d segments, want %d", i, len(got), len(want))
+		}
+		for s := range want {
+			for a := range want[s].Args {
+				x := float64(want[s].Args[a].X) - obliqueSlant*float64(want[s].Args[a].Y)
+				if dx := math.Abs(float64(got[s].Args[a].X) - x); dx > 128 {
+					t.Fatalf("glyph %d: point %v slanted to %v, want x %v", i, want[s].Args[a], got[s].Args[a], x)
+				}
+				if dy := got[s].Args[a].Y - want[s].Args[a].Y; dy < -128 || dy > 128 {
+					t.Fatalf("glyph %d: point %v moved up or down to %v", i, want[s].Args[a], got[s].Args[a])
+				}
+			}
+		}
+	}
+
+	// The advances are those of the upright face
+	advance := func(f *sfnt.Font, r rune) fixed.Int26_6 {
+		glyph, _ := f.GlyphIndex(nil, r)
+		width, _ := f.GlyphAdvance(nil, glyph, ppem, font.HintingNone)
+		return width
+	}
+	if advance(slanted, 'H') != advance(upright, 'H') {
+		t.Error("slanting changed the advance of H")
+	}
+}
+
+func TestSlantComposite(t *testing.T) {
+	// A glyph of two components: one placed with word offsets, one with byte offsets
+	var glyph bytes.Buffer
+	binary.Write(&glyph, binary.BigEndian, []int16{-1, 0, -10, 500, 700})
+	binary.Write(&glyph, binary.BigEndian, []uint16{argsAreWords | argsAreXY | moreComponents, 5, 100, 200})
+	binary.Write(&glyph, binary.BigEndian, []uint16{argsAreXY | haveCompInstr, 6})
+	glyph.Write([]byte{0xec, 100}) // -20, 100
+
+	out, box, err := slantComposite(glyph.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [4]int{slant(0, -10), -10, slant(500, 700), 700}; box != want {
+		t.Errorf("box %v, want %v", box, want)
+	}
+	words := make([]int16, len(out)/2)
+	binary.Read(bytes.NewReader(out), binary.BigEndian, words)
+	want := []int16{
+		-1, int16(box[0]), int16(box[1]), int16(box[2]), int16(box[3]),
+		argsAreWords | argsAreXY | moreComponents, 5, 142, 200,
+		argsAreWords | argsAreXY, 6, 1, 100,
+	}
+	if len(words) != len(want) {
+		t.Fatalf("composite written as %v, want %v", words, want)
+	}
+	for i := range want {
+		if words[i] != want[i] {
+			t.Fatalf("composite written as %v, want %v", words, want)
+		}
+	}
+
+	for n := 10; n < glyph.Len(); n++ {
+		if _, _, err := slantComposite(glyph.Bytes()[:n]); err == nil {
+			t.Errorf("composite cut at %d bytes was accepted", n)
+		}
+	}
+}
+
+func TestObliqueFontRejectsBrokenFonts(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("true"), goregular.TTF[:11], goregular.TTF[:100]} {
+		if _, err := obliqueFont(data); err == nil {
+			t.Errorf("font of %d bytes was accepted", len(data))
+		}
+	}
+
+	// A glyph location past the end of the glyph table
+	broken := bytes.Clone(goregular.TTF)
+	_, tables, err := readFontTables(broken, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loca := findFontTable(tables, "loca")
+	binary.BigEndian.PutUint16(loca[2*37:], 0xffff)
+	if _, err := obliqueFont(broken); err == nil {
+		t.Error("glyph out of the font was accepted")
+	}
+
+	// Glyphs cut short
+	for _, glyph := range [][]byte{{0, 1}, {0, 1, 0, 0, 0, 0, 0, 10, 0, 10, 0, 3}, {0, 1, 0, 0, 0, 0, 0, 10, 0, 10, 0, 3, 0, 0, 1}} {
+		if _, _, err := slantGlyph(glyph); err == nil {
+			t.Errorf("glyph %v was accepted", glyph)
+		}
+	}
+}
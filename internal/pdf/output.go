@@ -0,0 +1,280 @@
+package pdf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// outputPlaceholder matches the {name} placeholders of an output directory setting
+var outputPlaceholder = regexp.MustCompile(`\{[^}]*\}`)
+
+// DownloadDir returns the user's download directory: XDG_DOWNLOAD_DIR from the
+// environment or from the XDG user-dirs file, so localized desktops get their own
+// folder, and ~/Downloads otherwise
+func DownloadDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get home directory: %v", err)
+	}
+
+	if dir := os.Getenv("XDG_DOWNLOAD_DIR"); dir != "" {
+		return expandHome(dir, homeDir), nil
+	}
+
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		configDir = filepath.Join(homeDir, ".config")
+	}
+	if dir := readUserDir(filepath.Join(configDir, "user-dirs.dirs"), "XDG_DOWNLOAD_DIR"); dir != "" {
+		return expandHome(dir, homeDir), nil
+	}
+
+	return filepath.Join(homeDir, "Downloads"), nil
+}
+
+// readUserDir returns a directory set in an XDG user-dirs file, as in
+// XDG_DOWNLOAD_DIR="$HOME/Téléchargements", or "" when the file or the key is missing
+func readUserDir(path, key string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(name) != key {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return ""
+}
+
+// expandHome replaces a leading $HOME or ~ of a path with the home directory
+func expandHome(path, homeDir string) string {
+	for _, prefix := range []string{"$HOME", "${HOME}", "~"} {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return filepath.Join(homeDir, strings.TrimPrefix(path, prefix))
+		}
+	}
+	return path
+}
+
+// checkOutputDir rejects output directory settings with unknown placeholders
+func checkOutputDir(output string) error {
+	for _, placeholder := range outputPlaceholder.FindAllString(output, -1) {
+		switch placeholder {
+		case "{zone}", "{date}", "{courier}":
+		default:
+			return fmt.Errorf("unknown placeholder %s in output, expected {zone}, {date} or {courier}", placeholder)
+		}
+	}
+	return nil
+}
+
+// outputDir returns the directory the files of a manifest are saved to: the output
+// setting with its placeholders filled in, or the download directory when it is empty
+func outputDir(output string, manifest *Manifest) (string, error) {
+	if output == "" {
+		return DownloadDir()
+	}
+	if err := checkOutputDir(output); err != nil {
+		return "", err
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get home directory: %v", err)
+	}
+	dir := strings.NewReplacer(
+		"{zone}", dirName(manifest.Zone),
+		"{date}", manifest.Day().Format("2006-01-02"),
+		"{courier}", dirName(manifest.Courier),
+	).Replace(output)
+	return filepath.Clean(expandHome(dir, homeDir)), nil
+}
+
+// unnamedDir replaces an empty zone or courier in the output directory, so that
+// "out/{courier}/x" does not become "out/x" when no courier is given
+const unnamedDir = "tsy-voalaza"
+
+// dirName turns text into the name of a directory of the output setting, unnamedDir
+// when nothing is left of it
+func dirName(text string) string {
+	if name := safeName(text); name != "" {
+		return name
+	}
+	return unnamedDir
+}
+
+// safeName turns text into a single file or directory name: path separators, characters
+// Windows forbids and control characters become dashes, and leading or trailing dots
+// and spaces are dropped so "Analakely/Behoririka" stays one name
+func safeName(text string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range text {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || unicode.IsControl(r) {
+			if !dash {
+				sb.WriteRune('-')
+			}
+			dash = true
+			continue
+		}
+		sb.WriteRune(r)
+		dash = false
+	}
+	return strings.Trim(sb.String(), " .-")
+}
+
+// outputStem returns the file name of the output files of a manifest without their
+// suffix, numbered from _2 on for the nth try
+func outputStem(prefix string, manifest *Manifest, n int) string {
+	stem := prefix
+	if zone := safeName(manifest.Zone); zone != "" {
+		stem += "_" + zone
+	}
+	stem += "_" + manifest.Day().Format("2006-01-02")
+	if n > 1 {
+		stem = fmt.Sprintf("%s_%d", stem, n)
+	}
+	return stem
+}
+
+// saveFiles writes the output files of a manifest, one per suffix (for example ".pdf"),
+// and returns their paths. The files share a name numbered from _2 on when the first
+// try would overwrite a file, even one written meanwhile by another generation.
+func saveFiles(output, prefix string, manifest *Manifest, suffixes []string, data [][]byte) ([]string, error) {
+	dir, err := outputDir(output, manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create output directory %s: %v", dir, err)
+	}
+
+	// The content is written completely before any name is claimed, so a crash never
+	// leaves a half-written file behind
+	temps := make([]string, len(suffixes))
+	defer func() {
+		for _, temp := range temps {
+			if temp != "" {
+				os.Remove(temp)
+			}
+		}
+	}()
+	for i, suffix := range suffixes {
+		temp, err := writeTemp(dir, prefix+suffix, data[i])
+		if err != nil {
+			return nil, err
+		}
+		temps[i] = temp
+	}
+
+	for n := 1; ; n++ {
+		stem := outputStem(prefix, manifest, n)
+		names := make([]string, len(suffixes))
+		linked := make([]bool, len(suffixes))
+		taken := false
+		for i, suffix := range suffixes {
+			names[i] = filepath.Join(dir, stem+suffix)
+			linked[i], err = claimName(temps[i], names[i])
+			if err != nil {
+				// Give back the names claimed so far, so the files keep sharing a number
+				for _, name := range names[:i] {
+					os.Remove(name)
+				}
+				if !errors.Is(err, fs.ErrExist) {
+					return nil, fmt.Errorf("could not write %s: %v", names[i], err)
+				}
+				taken = true
+				break
+			}
+		}
+		if taken {
+			continue
+		}
+
+		for i, name := range names {
+			if linked[i] {
+				continue
+			}
+			if err := os.Rename(temps[i], name); err != nil {
+				for _, name := range names {
+					os.Remove(name)
+				}
+				return nil, fmt.Errorf("could not write %s: %v", name, err)
+			}
+			temps[i] = ""
+		}
+		return names, nil
+	}
+}
+
+// saveFile writes the content of an output file of a manifest and returns its path
+func saveFile(output, prefix string, manifest *Manifest, suffix string, data []byte) (string, error) {
+	names, err := saveFiles(output, prefix, manifest, []string{suffix}, [][]byte{data})
+	if err != nil {
+		return "", err
+	}
+	return names[0], nil
+}
+
+// writeTemp writes data to a new temporary file in dir and returns its path
+func writeTemp(dir, name string, data []byte) (string, error) {
+	file, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("could not write %s: %v", filepath.Join(dir, name), err)
+	}
+	temp := file.Name()
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp, 0644)
+	}
+	if err != nil {
+		os.Remove(temp)
+		return "", fmt.Errorf("could not write %s: %v", filepath.Join(dir, name), err)
+	}
+	return temp, nil
+}
+
+// claimName gives the complete temporary file temp the name filename, failing with
+// fs.ErrExist instead of replacing a file that has it. On file systems without hard
+// links, such as FAT memory cards, the name is held by an empty file instead, and
+// claimName reports that temp still has to be renamed over it.
+func claimName(temp, filename string) (linked bool, err error) {
+	err = os.Link(temp, filename)
+	if err == nil || errors.Is(err, fs.ErrExist) {
+		return err == nil, err
+	}
+
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return false, err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(filename)
+		return false, err
+	}
+	return false, nil
+}
@@ -0,0 +1,184 @@
+package pdf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSaveFilesNeverOverwrites(t *testing.T) {
+	dir := t.TempDir()
+	manifest := &Manifest{Zone: "Analakely", Date: time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local)}
+
+	// Lists of the same zone and day saved at the same time all get their own file
+	const saves = 20
+	paths := make([]string, saves)
+	errs := make([]error, saves)
+	var wg sync.WaitGroup
+	for i := 0; i < saves; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			paths[i], errs[i] = saveFile(dir, "fanatitra", manifest, ".pdf", []byte(fmt.Sprint(i)))
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i, path := range paths {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if seen[path] {
+			t.Errorf("%s saved twice", path)
+		}
+		seen[path] = true
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != fmt.Sprint(i) {
+			t.Errorf("%s holds %q, want %q", path, data, fmt.Sprint(i))
+		}
+	}
+
+	// Files saved together share a number, skipping the ones where any of them is taken
+	taken := filepath.Join(dir, "sary_Analakely_2026-10-19_2-1.png")
+	if err := os.WriteFile(taken, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := saveFile(dir, "sary", manifest, "-0.png", nil); err != nil {
+		t.Fatal(err)
+	}
+	names, err := saveFiles(dir, "sary", manifest, []string{"-0.png", "-1.png"}, [][]byte{nil, nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range names {
+		if want := filepath.Join(dir, fmt.Sprintf("sary_Analakely_2026-10-19_3-%d.png", i)); name != want {
+			t.Errorf("saved as %s, want %s", name, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sary_Analakely_2026-10-19_2-0.png")); err == nil {
+		t.Error("the name given back when its pair was taken was left behind")
+	}
+
+	// No temporary file is left
+	temps, _ := filepath.Glob(filepath.Join(dir, ".*.tmp"))
+	if len(temps) > 0 {
+		t.Errorf("temporary files left: %v", temps)
+	}
+}
+
+func TestDownloadDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_DOWNLOAD_DIR", "")
+
+	dir, err := DownloadDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, "Downloads"); dir != want {
+		t.Errorf("download directory %s without user-dirs file, want %s", dir, want)
+	}
+
+	// A French desktop names it in the user-dirs file
+	userDirs := strings.Join([]string{
+		"# This file is written by xdg-user-dirs-update",
+		`XDG_DESKTOP_DIR="$HOME/Bureau"`,
+		`XDG_DOWNLOAD_DIR="$HOME/Téléchargements"`,
+	}, "\n")
+	if err := os.MkdirAll(filepath.Join(home, ".config"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(home, ".config", "user-dirs.dirs")
+	if err := os.WriteFile(path, []byte(userDirs), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := readUserDir(path, "XDG_DOWNLOAD_DIR"); got != "$HOME/Téléchargements" {
+		t.Errorf("readUserDir = %q, want %q", got, "$HOME/Téléchargements")
+	}
+	if got := readUserDir(path, "XDG_MUSIC_DIR"); got != "" {
+		t.Errorf("readUserDir = %q for a missing key, want \"\"", got)
+	}
+	dir, err = DownloadDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, "Téléchargements"); dir != want {
+		t.Errorf("download directory %s, want %s", dir, want)
+	}
+
+	// The environment takes precedence over the file
+	t.Setenv("XDG_DOWNLOAD_DIR", "~/Fichiers")
+	dir, err = DownloadDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, "Fichiers"); dir != want {
+		t.Errorf("download directory %s with XDG_DOWNLOAD_DIR set, want %s", dir, want)
+	}
+}
+
+func TestOutputDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		output   string
+		manifest *Manifest
+		want     string
+	}{
+		{"~/Livraisons/{date}/{courier}", &Manifest{Zone: "Ivato", Courier: "Rado", Date: day}, filepath.Join(home, "Livraisons", "2026-10-19", "Rado")},
+		{"/srv/out/{zone}", &Manifest{Zone: "Analakely/Behoririka", Date: day}, "/srv/out/Analakely-Behoririka"},
+		{"/srv/out/{zone}", &Manifest{Zone: "../..", Date: day}, "/srv/out/" + unnamedDir},
+		// An empty courier keeps its directory level
+		{"/srv/out/{courier}/lisitra", &Manifest{Zone: "Ivato", Date: day}, "/srv/out/" + unnamedDir + "/lisitra"},
+	}
+	for _, tt := range tests {
+		dir, err := outputDir(tt.output, tt.manifest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dir != tt.want {
+			t.Errorf("outputDir(%q) = %s, want %s", tt.output, dir, tt.want)
+		}
+	}
+
+	if _, err := outputDir("/srv/out/{month}", &Manifest{Date: day}); err == nil {
+		t.Error("unknown placeholder accepted")
+	}
+}
+
+func TestSafeName(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Analakely", "Analakely"},
+		{"Analakely/Behoririka", "Analakely-Behoririka"},
+		{`Ivato\Aéroport`, "Ivato-Aéroport"},
+		{"Zone: 67 Ha?", "Zone- 67 Ha"},
+		{"a//b", "a-b"},
+		{"Tana\tNord", "Tana-Nord"},
+		{" ..Ambohidratrimo. ", "Ambohidratrimo"},
+		{"..", ""},
+		{"/", ""},
+	}
+	for _, tt := range tests {
+		got := safeName(tt.text)
+		if got != tt.want {
+			t.Errorf("safeName(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+		if strings.ContainsAny(got, `/\`) || filepath.Base(got) != got && got != "" {
+			t.Errorf("safeName(%q) = %q is not a single path component", tt.text, got)
+		}
+	}
+}
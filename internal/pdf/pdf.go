@@ -1,32 +1,144 @@
 package pdf
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
 	"strings"
-	"time"
 
 	"github.com/signintech/gopdf"
 )
 
 type PDFConfig struct {
-	PageWidth      float64
-	MarginLeft     float64
-	MarginRight    float64
-	MarginTop      float64
-	MarginBottom   float64
-	LineHeight     float64
-	SectionSpacing float64
-	ItemSpacing    float64
-	NoteBoxHeight  float64
-	NameSpacing    float64
-	EntrySpacing   float64
-	ItemWidth      float64
-	DateSpacing    float64
-	PhoneSpacing   float64
-	ZoneSpacing    float64
-	AddressSpacing float64
+	PageWidth      float64    `toml:"page_width"`
+	PageHeight     float64    `toml:"page_height"` // Height of a sheet, 0 for a continuous roll cut to the content
+	MarginLeft     float64    `toml:"margin_left"`
+	MarginRight    float64    `toml:"margin_right"`
+	MarginTop      float64    `toml:"margin_top"`
+	MarginBottom   float64    `toml:"margin_bottom"`
+	LineHeight     float64    `toml:"line_height"`
+	SectionSpacing float64    `toml:"section_spacing"`
+	ItemSpacing    float64    `toml:"item_spacing"`
+	NoteBoxHeight  float64    `toml:"note_box_height"`
+	NameSpacing    float64    `toml:"name_spacing"`
+	EntrySpacing   float64    `toml:"entry_spacing"`
+	ItemWidth      float64    `toml:"item_width"`
+	DateSpacing    float64    `toml:"date_spacing"`
+	PhoneSpacing   float64    `toml:"phone_spacing"`
+	ZoneSpacing    float64    `toml:"zone_spacing"`
+	AddressSpacing float64    `toml:"address_spacing"`
+	Hyphenate      bool       `toml:"hyphenate"`     // Hyphenate words split across lines
+	MissingGlyph   string     `toml:"missing_glyph"` // Drawn for characters no font covers and that have no transliteration
+	Output         string     `toml:"output"`        // Directory files are saved to, with {zone}, {date} and {courier} placeholders; the download directory when empty
+	DPI            float64    `toml:"dpi"`           // Resolution of PNG exports, in dots per inch
+	Fonts          FontConfig `toml:"fonts"`
+	Labels         Labels     `toml:"labels"`
+	Template       *Template  `toml:"-"` // Sections printed for each entry, the default template when nil
+	Typography     Typography `toml:"typography"`
+}
+
+// TextStyle is the size in points, the weight and the slant of a typographic role
+type TextStyle struct {
+	Size   float64 `toml:"size"`
+	Bold   bool    `toml:"bold"`
+	Italic bool    `toml:"italic"`
+}
+
+// Style returns the font face of the text style
+func (s TextStyle) Style() FontStyle {
+	return fontStyle(s.Bold, s.Italic)
+}
+
+// Typography assigns a text style to each role of the manifest
+type Typography struct {
+	Header TextStyle `toml:"header"` // Zone name
+	Name   TextStyle `toml:"name"`   // Customer name and total
+	Meta   TextStyle `toml:"meta"`   // Customer ID
+	Body   TextStyle `toml:"body"`   // Address, phone, items, notes and the deliverer box
+	Footer TextStyle `toml:"footer"` // Date and quote
+	Slogan TextStyle `toml:"slogan"` // Closing slogan
+	Scale  float64   `toml:"scale"`  // Multiplies every font size and spacing, 0 is treated as 1
+}
+
+// DefaultTypography returns the font sizes of the original manifest
+func DefaultTypography() Typography {
+	return Typography{
+		Header: TextStyle{Size: 12, Bold: true},
+		Name:   TextStyle{Size: 9, Bold: true},
+		Meta:   TextStyle{Size: 7},
+		Body:   TextStyle{Size: 8},
+		Footer: TextStyle{Size: 8},
+		Slogan: TextStyle{Size: 11},
+		Scale:  1.0,
+	}
+}
+
+// scaled returns the typography with the scale factor applied to every role
+func (t Typography) scaled() Typography {
+	if t.Scale <= 0 {
+		t.Scale = 1.0
+	}
+	for _, role := range []*TextStyle{&t.Header, &t.Name, &t.Meta, &t.Body, &t.Footer, &t.Slogan} {
+		role.Size *= t.Scale
+	}
+	return t
+}
+
+// scaledSpacing returns a copy of the config with its spacings multiplied by scale,
+// leaving the page size and margins untouched
+func (c *PDFConfig) scaledSpacing(scale float64) *PDFConfig {
+	scaled := *c
+	for _, spacing := range []*float64{
+		&scaled.LineHeight, &scaled.SectionSpacing, &scaled.ItemSpacing, &scaled.NoteBoxHeight,
+		&scaled.NameSpacing, &scaled.EntrySpacing, &scaled.ItemWidth, &scaled.DateSpacing,
+		&scaled.PhoneSpacing, &scaled.ZoneSpacing, &scaled.AddressSpacing,
+	} {
+		*spacing *= scale
+	}
+	return &scaled
+}
+
+// Labels holds the captions printed on every manifest output
+type Labels struct {
+	ID             string `toml:"id"`
+	NoPhone        string `toml:"no_phone"`
+	Items          string `toml:"items"`
+	Gift           string `toml:"gift"`
+	Notes          string `toml:"notes"`
+	DelivererNotes string `toml:"deliverer_notes"`
+	Currency       string `toml:"currency"`
+	Date           string `toml:"date"`
+	Courier        string `toml:"courier"`
+	Shop           string `toml:"shop"`
+	Zone           string `toml:"zone"` // Column captions of the batch report
+	Count          string `toml:"count"`
+	Amount         string `toml:"amount"`
+	Total          string `toml:"total"`
+	Quote          string `toml:"quote"`
+	Slogan         string `toml:"slogan"`
+}
+
+// DefaultLabels returns the captions used since the first version of the manifest
+func DefaultLabels() Labels {
+	return Labels{
+		ID:             "ID",
+		NoPhone:        "Tsisy lty a! Tsisy",
+		Items:          "Entam-be:",
+		Gift:           "Kadoa",
+		Notes:          "Notes:",
+		DelivererNotes: "Watawata:",
+		Currency:       "Ar",
+		Date:           "Daty:",
+		Courier:        "Livreur:",
+		Shop:           "Fivarotana:",
+		Zone:           "Trasy",
+		Count:          "Isa",
+		Amount:         "Vola",
+		Total:          "Totaly",
+		Quote:          "\"Taloha sarotra nirahana, ankehitriny lasa livreur.🥲\"",
+		Slogan:         "KIMBASÔ !",
+	}
 }
 
 func DefaultConfig() *PDFConfig {
@@ -42,292 +154,343 @@ func DefaultConfig() *PDFConfig {
 		NoteBoxHeight:  8.0,
 		NameSpacing:    2.0,
 		EntrySpacing:   4.0,
-		ItemWidth:      25.0,
+		ItemWidth:      23.0, // Three columns between the 4mm margins, 3x25mm ran 5mm past the right edge of the roll
 		DateSpacing:    3.0,
 		PhoneSpacing:   2.0,
 		ZoneSpacing:    3.0,
 		AddressSpacing: 1.0,
+		MissingGlyph:   "?",
+		DPI:            200.0,
+		Labels:         DefaultLabels(),
+		Typography:     DefaultTypography(),
 	}
 }
 
-func GeneratePDF(zone string, entries []DeliveryEntry, config *PDFConfig) error {
-	if config == nil {
-		config = DefaultConfig()
+// PaperNames lists the paper presets known to PaperPreset
+var PaperNames = []string{"58mm", "78mm", "80mm", "a4"}
+
+// PaperPreset returns the default config laid out for a named paper: 58mm, 78mm or
+// 80mm thermal rolls, or A4 sheets
+func PaperPreset(name string) (*PDFConfig, error) {
+	config := DefaultConfig()
+	switch strings.ToLower(name) {
+	case "78mm":
+	case "58mm":
+		config.PageWidth = 58.0
+		config.MarginLeft = 2.0
+		config.MarginRight = 2.0
+		config.ItemWidth = 18.0
+	case "80mm":
+		config.PageWidth = 80.0
+		config.ItemWidth = 24.0
+	case "a4":
+		config.PageWidth = 210.0
+		config.PageHeight = 297.0
+		config.MarginLeft = 15.0
+		config.MarginRight = 15.0
+		config.MarginTop = 15.0
+		config.MarginBottom = 15.0
+		config.ItemWidth = 30.0
+	default:
+		return nil, fmt.Errorf("unknown paper %q, expected one of %s", name, strings.Join(PaperNames, ", "))
 	}
+	return config, nil
+}
 
-	pdf := gopdf.GoPdf{}
-	totalHeight := (float64(len(entries)) * 50) + 30
-	pdf.Start(gopdf.Config{
-		PageSize: gopdf.Rect{W: config.PageWidth, H: totalHeight},
-		Unit:     gopdf.Unit_MM,
-	})
-
-	fontPaths, err := FindFont()
+// ApplyPaper replaces the page size, margins and item width with those of a paper preset,
+// keeping the spacings, labels and typography
+func (c *PDFConfig) ApplyPaper(name string) error {
+	preset, err := PaperPreset(name)
 	if err != nil {
-		fontPaths, err = SetupFallbackFonts()
-		if err != nil {
-			return fmt.Errorf("could not setup fonts: %v", err)
+		return err
+	}
+
+	c.PageWidth, c.PageHeight = preset.PageWidth, preset.PageHeight
+	c.MarginLeft, c.MarginRight = preset.MarginLeft, preset.MarginRight
+	c.MarginTop, c.MarginBottom = preset.MarginTop, preset.MarginBottom
+	c.ItemWidth = preset.ItemWidth
+	return nil
+}
+
+// Validate rejects configs whose content would be drawn off the page, naming the offending field
+func (c *PDFConfig) Validate() error {
+	if c.PageWidth <= 0 {
+		return fmt.Errorf("PageWidth must be positive, got %v", c.PageWidth)
+	}
+	if c.ItemWidth <= 0 {
+		return fmt.Errorf("ItemWidth must be positive, got %v", c.ItemWidth)
+	}
+	if c.DPI <= 0 {
+		return fmt.Errorf("DPI must be positive, got %v", c.DPI)
+	}
+
+	fields := []struct {
+		name  string
+		value float64
+	}{
+		{"PageHeight", c.PageHeight},
+		{"MarginLeft", c.MarginLeft},
+		{"MarginRight", c.MarginRight},
+		{"MarginTop", c.MarginTop},
+		{"MarginBottom", c.MarginBottom},
+		{"LineHeight", c.LineHeight},
+		{"SectionSpacing", c.SectionSpacing},
+		{"ItemSpacing", c.ItemSpacing},
+		{"NoteBoxHeight", c.NoteBoxHeight},
+		{"NameSpacing", c.NameSpacing},
+		{"EntrySpacing", c.EntrySpacing},
+		{"ItemWidth", c.ItemWidth},
+		{"DateSpacing", c.DateSpacing},
+		{"PhoneSpacing", c.PhoneSpacing},
+		{"ZoneSpacing", c.ZoneSpacing},
+		{"AddressSpacing", c.AddressSpacing},
+		{"Typography.Scale", c.Typography.Scale},
+	}
+	for _, field := range fields {
+		if field.value < 0 {
+			return fmt.Errorf("%s must not be negative, got %v", field.name, field.value)
 		}
 	}
 
-	err = pdf.AddTTFFont("regular", fontPaths.Regular)
-	if err != nil {
-		return fmt.Errorf("could not load regular font %s: %v", fontPaths.Regular, err)
+	contentWidth := c.PageWidth - c.MarginLeft - c.MarginRight
+	if contentWidth <= 0 {
+		return fmt.Errorf("MarginLeft (%vmm) and MarginRight (%vmm) leave no room on a PageWidth of %vmm", c.MarginLeft, c.MarginRight, c.PageWidth)
+	}
+	if c.PageHeight > 0 && c.MarginTop+c.MarginBottom >= c.PageHeight {
+		return fmt.Errorf("MarginTop (%vmm) and MarginBottom (%vmm) leave no room on a PageHeight of %vmm", c.MarginTop, c.MarginBottom, c.PageHeight)
+	}
+	if c.ItemWidth > contentWidth {
+		return fmt.Errorf("ItemWidth (%vmm) is wider than the %vmm between the margins", c.ItemWidth, contentWidth)
 	}
+	if err := checkOutputDir(c.Output); err != nil {
+		return err
+	}
+
+	return nil
+}
 
-	err = pdf.AddTTFFont("bold", fontPaths.Bold)
+// GeneratePDF saves a manifest as a PDF in the output directory and returns its path
+func GeneratePDF(manifest *Manifest, config *PDFConfig) (string, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	data, err := RenderPDF(context.Background(), manifest, config)
 	if err != nil {
-		return fmt.Errorf("could not load bold font %s: %v", fontPaths.Bold, err)
+		return "", err
 	}
 
-	pdf.AddPage()
-	currentY := config.MarginTop
+	return saveFile(config.Output, "fanatitra", manifest, ".pdf", data)
+}
 
-	logoPath := "assets/logo.png"
-	if _, err := os.Stat(logoPath); err == nil {
-		pdf.Image(logoPath, (config.PageWidth-20)/2, currentY, &gopdf.Rect{W: 18, H: 16})
-		currentY += 12
+// RenderPDF returns a manifest as the content of a PDF file
+func RenderPDF(ctx context.Context, manifest *Manifest, config *PDFConfig) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := WritePDF(ctx, &buf, manifest, config); err != nil {
+		return nil, err
 	}
+	return buf.Bytes(), nil
+}
 
-	// Add Zone header
-	pdf.SetFont("regular", "", 10)
-	pdf.SetX(config.MarginLeft)
-	pdf.SetY(currentY)
-	pdf.SetFont("bold", "", 12)
+// WritePDF lays out a manifest and writes it as PDF to w, with the default config when
+// config is nil. It gives up with the context's error once ctx is done.
+func WritePDF(ctx context.Context, w io.Writer, manifest *Manifest, config *PDFConfig) error {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("invalid PDF config: %v", err)
+	}
 
-	// Calculate available width for zone text
-	availableWidth := config.PageWidth - config.MarginLeft - config.MarginRight - 4
-	zoneLines := wrapText(&pdf, zone, availableWidth)
-	for _, line := range zoneLines {
-		pdf.SetX(config.MarginLeft + 2)
-		pdf.SetY(currentY)
-		pdf.Cell(nil, line)
-		currentY += config.LineHeight + 1.0
+	fonts, err := LoadFonts(config.Fonts)
+	if err != nil {
+		return err
 	}
-	currentY += config.ZoneSpacing
 
-	for i, entry := range entries {
-		pdf.SetFont("bold", "", 9)
+	renderer, err := NewGopdfRenderer(fonts)
+	if err != nil {
+		return err
+	}
 
-		total := entry.CalculateTotal()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	doc := BuildLayout(manifest, config, renderer)
 
-		// Header with customer name and total on the right
-		pdf.SetX(config.MarginLeft)
-		pdf.SetY(currentY)
-		pdf.Cell(nil, entry.Name)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return renderer.Render(doc, w)
+}
 
-		// Total on the right
-		totalText := fmt.Sprintf("%s Ar", FormatNumber(total))
-		totalWidth, _ := pdf.MeasureTextWidth(totalText)
-		pdf.SetX(config.PageWidth - config.MarginRight - totalWidth)
-		pdf.Cell(nil, totalText)
+// GopdfRenderer draws documents as PDF through gopdf and measures text with the same fonts
+type GopdfRenderer struct {
+	pdf        *gopdf.GoPdf
+	chain      *FontChain
+	registered map[string]bool
+	err        error // First font error met while measuring, returned by Render
+}
 
-		currentY += config.LineHeight + 2.0
+// NewGopdfRenderer prepares a PDF drawn with the font chains. Fonts are registered
+// when first used, so the PDF only embeds, and gopdf only parses, the fonts it needs;
+// gopdf has no way to reuse a font parsed for an earlier PDF.
+func NewGopdfRenderer(fonts *FontChain) (*GopdfRenderer, error) {
+	pdf := &gopdf.GoPdf{}
+	pdf.Start(gopdf.Config{
+		PageSize: *gopdf.PageSizeA4,
+		Unit:     gopdf.Unit_MM,
+	})
 
-		pdf.SetFont("regular", "", 7)
-		pdf.SetX(config.MarginLeft)
-		pdf.SetY(currentY)
-		if entry.ID == "" {
-			pdf.Cell(nil, "ID: -")
-		} else {
-			pdf.Cell(nil, fmt.Sprintf("ID: %s", entry.ID))
-		}
-		currentY += config.LineHeight + config.NameSpacing
-
-		// Address with wrapping
-		pdf.SetFont("regular", "", 8)
-		addressLines := wrapText(&pdf, entry.Address, config.PageWidth-config.MarginLeft-config.MarginRight-7) // -7 for icon and spacing
-		for _, line := range addressLines {
-			pdf.SetX(config.MarginLeft + 6)
-			pdf.SetY(currentY)
-			if line == addressLines[0] {
-				pdf.SetX(config.MarginLeft)
-				pdf.Cell(nil, ">")
-				pdf.SetX(config.MarginLeft + 4)
-			}
-			pdf.Cell(nil, line)
-			currentY += config.LineHeight + 0.5
-		}
-		currentY += config.AddressSpacing
-
-		// Phone number
-		pdf.SetX(config.MarginLeft)
-		pdf.SetY(currentY)
-		pdf.Cell(nil, "#")
-		pdf.SetX(config.MarginLeft + 4)
-		if entry.Phone == "" {
-			pdf.Cell(nil, "Tsisy lty a! Tsisy")
-		} else {
-			pdf.Cell(nil, entry.Phone)
-		}
-		currentY += config.LineHeight + config.PhoneSpacing
-
-		// Items section
-		pdf.SetX(config.MarginLeft)
-		pdf.SetY(currentY)
-		pdf.Cell(nil, "Entam-be:")
-		currentY += config.LineHeight + config.ItemSpacing
-
-		items := strings.Split(entry.Items, "+")
-		for i := 0; i < len(items); i += 3 {
-			for j := 0; j < 3 && i+j < len(items); j++ {
-				price := strings.TrimSpace(items[i+j])
-				p, err := parseFloat(price)
-				if err == nil {
-					pdf.SetX(config.MarginLeft + (config.ItemWidth * float64(j)))
-					pdf.SetY(currentY)
-					if p == 0 {
-						pdf.Cell(nil, "• Kadoa")
-					} else {
-						pdf.Cell(nil, fmt.Sprintf("• %sk", FormatNumber(p/1000)))
-					}
-				}
-			}
-			currentY += config.LineHeight + config.ItemSpacing
-		}
+	return &GopdfRenderer{pdf: pdf, chain: fonts, registered: make(map[string]bool)}, nil
+}
 
-		// Notes from customer
-		if entry.Notes != "" {
-			currentY += config.SectionSpacing
-			pdf.SetFont("bold", "", 8)
-			pdf.SetX(config.MarginLeft)
-			pdf.SetY(currentY)
-			pdf.Cell(nil, "Notes:")
-			pdf.SetX(config.MarginLeft + 12)
-			pdf.SetFont("regular", "", 8)
-
-			// Calculate available width for notes text
-			notesWidth := config.PageWidth - config.MarginLeft - config.MarginRight - 12
-			noteLines := wrapText(&pdf, entry.Notes, notesWidth)
-			for _, line := range noteLines {
-				pdf.SetX(config.MarginLeft + 12)
-				pdf.SetY(currentY)
-				pdf.Cell(nil, line)
-				currentY += config.LineHeight + 0.5
-			}
+// setFont selects a font of a style's chain, registering it on first use
+func (r *GopdfRenderer) setFont(style FontStyle, index int, size float64) error {
+	family := fontFamily(style, index)
+	if !r.registered[family] {
+		font := r.chain.fonts[style][index]
+		if err := r.pdf.AddTTFFontData(family, font.Data); err != nil {
+			return fmt.Errorf("could not load font %s: %v", font.Name, err)
 		}
+		r.registered[family] = true
+	}
+	return r.pdf.SetFont(family, "", size)
+}
 
-		// Note-taking box for deliverer
-		currentY += config.SectionSpacing
-		pdf.SetLineWidth(0.1)
-		pdf.SetLineType("dashed")
-		noteBoxY := currentY
-		pdf.Line(config.MarginLeft, noteBoxY, config.PageWidth-config.MarginRight, noteBoxY)                                           // Top
-		pdf.Line(config.MarginLeft, noteBoxY+config.NoteBoxHeight, config.PageWidth-config.MarginRight, noteBoxY+config.NoteBoxHeight) // Bottom
-		pdf.Line(config.MarginLeft, noteBoxY, config.MarginLeft, noteBoxY+config.NoteBoxHeight)                                        // Left
-		pdf.Line(config.PageWidth-config.MarginRight, noteBoxY, config.PageWidth-config.MarginRight, noteBoxY+config.NoteBoxHeight)    // Right
-
-		pdf.SetFont("regular", "", 6)
-		pdf.SetFont("bold", "", 8)
-		pdf.SetX(config.MarginLeft + 1)
-		pdf.SetY(noteBoxY + 2)
-		pdf.Cell(nil, "Watawata:")
-		currentY += config.NoteBoxHeight
-
-		pdf.SetLineType("solid")
-		if i < len(entries)-1 {
-			currentY += config.EntrySpacing / 2
-			pdf.SetLineWidth(0.3)
-			pdf.Line(config.MarginLeft, currentY, config.PageWidth-config.MarginRight, currentY)
-			currentY += config.EntrySpacing / 2
+// MeasureText implements Measurer, adding up the parts drawn with each font of the chain.
+// A font that cannot be used measures 0, and its error is returned by Render.
+func (r *GopdfRenderer) MeasureText(text string, style FontStyle, size float64) float64 {
+	total := 0.0
+	for _, run := range r.chain.runs(text, style) {
+		if err := r.setFont(style, run.font, size); err != nil {
+			r.fail(err)
+			return 0
+		}
+		width, err := r.pdf.MeasureTextWidth(run.text)
+		if err != nil {
+			r.fail(fmt.Errorf("could not measure %q: %v", text, err))
+			return 0
 		}
+		total += width
 	}
+	return total
+}
 
-	// Add current date
-	currentY += config.DateSpacing
-	pdf.SetFont("regular", "", 8)
-	dateStr := time.Now().Format("02/01/2006")
-	dateWidth, _ := pdf.MeasureTextWidth(dateStr)
-	pdf.SetX((config.PageWidth - dateWidth) / 2)
-	pdf.SetY(currentY)
-	pdf.Cell(nil, dateStr)
-
-	currentY += config.LineHeight + 2.0
-	quote := "\"Taloha sarotra nirahana, ankehitriny lasa livreur.🥲\""
-	quoteWidth, _ := pdf.MeasureTextWidth(quote)
-	pdf.SetFont("bold", "I", 10)
-	pdf.SetX((config.PageWidth - quoteWidth) / 2)
-	pdf.SetY(currentY)
-	pdf.Cell(nil, quote)
-
-	// Add the final word to this non-sense
-	currentY += config.LineHeight + 2.0
-	slogan := "KIMBASÔ !"
-	sloganWidth, _ := pdf.MeasureTextWidth(slogan)
-	pdf.SetFont("bold", "", 14)
-	pdf.SetFont("regular", "", 11)
-	pdf.SetX((config.PageWidth - sloganWidth) / 2)
-	pdf.SetY(currentY)
-	pdf.Cell(nil, slogan)
-
-	// Get user's home directory
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("could not get home directory: %v", err)
+// fail keeps the first error met while measuring
+func (r *GopdfRenderer) fail(err error) {
+	if r.err == nil {
+		r.err = err
 	}
+}
 
-	downloadsDir := filepath.Join(homeDir, "Downloads")
-
-	if err := os.MkdirAll(downloadsDir, 0755); err != nil {
-		return fmt.Errorf("could not create Downloads directory: %v", err)
+// drawText draws a text with its top left corner at x, y, each part with the first
+// font of the chain that covers it
+func (r *GopdfRenderer) drawText(x, y float64, text string, style FontStyle, size float64) error {
+	for _, part := range r.chain.runs(text, style) {
+		if err := r.setFont(style, part.font, size); err != nil {
+			return fmt.Errorf("could not set font for %q: %v", text, err)
+		}
+		r.pdf.SetXY(x, y)
+		if err := r.pdf.Cell(nil, part.text); err != nil {
+			return fmt.Errorf("could not draw %q: %v", text, err)
+		}
+		width, err := r.pdf.MeasureTextWidth(part.text)
+		if err != nil {
+			return fmt.Errorf("could not measure %q: %v", text, err)
+		}
+		x += width
 	}
+	return nil
+}
 
-	timestamp := time.Now().Format("2006-01-02")
-	filename := filepath.Join(downloadsDir, fmt.Sprintf("fanatitra_%s_%s.pdf", zone, timestamp))
-
-	return pdf.WritePdf(filename)
+// HasGlyph implements GlyphChecker
+func (r *GopdfRenderer) HasGlyph(ch rune, style FontStyle) bool {
+	return r.chain.HasGlyph(ch, style)
 }
 
-func wrapText(pdf *gopdf.GoPdf, text string, maxWidth float64) []string {
-	var lines []string
-	words := strings.Fields(text)
-	currentLine := ""
+// Render implements Renderer, adding the document as a page sized to fit it,
+// or as pages of its page height
+func (r *GopdfRenderer) Render(doc *Document, w io.Writer) error {
+	if r.err != nil {
+		return r.err
+	}
+	pdf := r.pdf
+	pageHeight := doc.Height
+	if doc.PageHeight > 0 {
+		pageHeight = doc.PageHeight
+	}
+	for i := 0; i < doc.Pages(); i++ {
+		pdf.AddPageWithOption(gopdf.PageOption{
+			PageSize: &gopdf.Rect{W: doc.Width, H: pageHeight},
+		})
+	}
 
-	for _, word := range words {
-		testLine := currentLine
-		if testLine != "" {
-			testLine += " "
+	// Switches to the page y falls on and returns y from the top of that page
+	onPage := func(y float64) float64 {
+		page, pageY := doc.PageOf(y)
+		pdf.SetPage(page + 1)
+		return pageY
+	}
+
+	for _, block := range doc.Blocks {
+		for _, img := range block.Images {
+			y := onPage(img.Y)
+			pdf.Image(img.Path, img.X, y, &gopdf.Rect{W: img.W, H: img.H})
 		}
-		testLine += word
-
-		width, _ := pdf.MeasureTextWidth(testLine)
-		if width > maxWidth {
-			// Line would be too long
-			if currentLine != "" {
-				// If we already have content, start a new line
-				lines = append(lines, currentLine)
-				currentLine = word
+
+		for _, box := range block.Boxes {
+			y := onPage(box.Y)
+			pdf.SetLineWidth(box.LineWidth)
+			setLineType(pdf, box.Dashed)
+			if box.Filled {
+				pdf.SetFillColor(0, 0, 0)
+				pdf.RectFromUpperLeftWithStyle(box.X, y, box.W, box.H, "F")
 			} else {
-				// For long words, split them into smaller chunks
-				remainingWord := word
-				for len(remainingWord) > 0 {
-					// Try to find a good breaking point
-					splitPoint := len(remainingWord)
-					for i := 0; i < len(remainingWord); i++ {
-						testChunk := remainingWord[:i+1]
-						width, _ := pdf.MeasureTextWidth(testChunk)
-						if width > maxWidth {
-							splitPoint = i
-							break
-						}
-					}
-
-					// Add the chunk to lines
-					lines = append(lines, remainingWord[:splitPoint])
-					remainingWord = remainingWord[splitPoint:]
-				}
-				currentLine = ""
+				pdf.RectFromUpperLeftWithStyle(box.X, y, box.W, box.H, "D")
+			}
+		}
+
+		for _, line := range block.Lines {
+			y := onPage(line.Y1)
+			pdf.SetLineWidth(line.Width)
+			setLineType(pdf, line.Dashed)
+			pdf.Line(line.X1, y, line.X2, y+line.Y2-line.Y1)
+		}
+
+		for _, run := range block.Texts {
+			y := onPage(run.Y)
+			if err := r.drawText(run.X, y, run.Text, run.Style, run.Size); err != nil {
+				return err
 			}
-		} else {
-			// Add word to current line
-			currentLine = testLine
 		}
 	}
 
-	// Add the last line if not empty
-	if currentLine != "" {
-		lines = append(lines, currentLine)
+	_, err := pdf.WriteTo(w)
+	return err
+}
+
+// fontFamilies are the names the first font of each style is registered under
+var fontFamilies = map[FontStyle]string{
+	FontRegular:    "regular",
+	FontBold:       "bold",
+	FontItalic:     "italic",
+	FontBoldItalic: "bold-italic",
+}
+
+// fontFamily returns the name a font of a style's chain is registered under: the name
+// of the style for the first font, followed by its position for the fallbacks
+func fontFamily(style FontStyle, index int) string {
+	family := fontFamilies[style]
+	if index > 0 {
+		family = fmt.Sprintf("%s-%d", family, index)
 	}
+	return family
+}
 
-	return lines
+// setLineType switches between dashed and solid strokes
+func setLineType(pdf *gopdf.GoPdf, dashed bool) {
+	if dashed {
+		pdf.SetLineType("dashed")
+	} else {
+		pdf.SetLineType("solid")
+	}
 }
@@ -0,0 +1,260 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math"
+	"os"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// faceKey identifies a font face by style, position in the font chain and size
+type faceKey struct {
+	style FontStyle
+	font  int
+	size  float64
+}
+
+// RasterRenderer draws documents as PNG images with the same fonts as the PDF
+type RasterRenderer struct {
+	DPI   float64
+	chain *FontChain
+	faces map[faceKey]font.Face
+	err   error // First font error met while measuring or drawing, see Err
+}
+
+// NewRasterRenderer prepares drawing with a font chain at the given resolution
+func NewRasterRenderer(dpi float64, fonts *FontChain) (*RasterRenderer, error) {
+	if dpi <= 0 {
+		return nil, fmt.Errorf("DPI must be positive, got %v", dpi)
+	}
+
+	return &RasterRenderer{
+		DPI:   dpi,
+		chain: fonts,
+		faces: make(map[faceKey]font.Face),
+	}, nil
+}
+
+// face returns the cached face for a font of a style's chain at a size
+func (r *RasterRenderer) face(style FontStyle, index int, size float64) font.Face {
+	key := faceKey{style: style, font: index, size: size}
+	if face, ok := r.faces[key]; ok {
+		return face
+	}
+
+	face, err := opentype.NewFace(r.chain.fonts[style][index].Font, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     r.DPI,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		if r.err == nil {
+			r.err = fmt.Errorf("could not load font %s at %vpt: %v", r.chain.fonts[style][index].Name, size, err)
+		}
+		return nil
+	}
+	r.faces[key] = face
+	return face
+}
+
+// Err returns the first font error met while measuring or drawing. The text of a font
+// that cannot be used is measured as 0 wide and left out of the images.
+func (r *RasterRenderer) Err() error {
+	return r.err
+}
+
+// px converts millimeters to pixels
+func (r *RasterRenderer) px(mm float64) int {
+	return int(math.Round(mm * r.DPI / 25.4))
+}
+
+// MeasureText implements Measurer, adding up the parts drawn with each font of the chain
+func (r *RasterRenderer) MeasureText(text string, style FontStyle, size float64) float64 {
+	var width fixed.Int26_6
+	for _, run := range r.chain.runs(text, style) {
+		face := r.face(style, run.font, size)
+		if face == nil {
+			return 0
+		}
+		width += font.MeasureString(face, run.text)
+	}
+	return float64(width) / 64 * 25.4 / r.DPI
+}
+
+// HasGlyph implements GlyphChecker
+func (r *RasterRenderer) HasGlyph(ch rune, style FontStyle) bool {
+	return r.chain.HasGlyph(ch, style)
+}
+
+// Render implements Renderer, writing the whole document as one tall PNG
+func (r *RasterRenderer) Render(doc *Document, w io.Writer) error {
+	img := r.RenderRegion(doc, 0, doc.Height)
+	if r.err != nil {
+		return r.err
+	}
+	return png.Encode(w, img)
+}
+
+// RenderEntries draws each entry block of the document as its own image
+func (r *RasterRenderer) RenderEntries(doc *Document) []image.Image {
+	var images []image.Image
+	for _, block := range doc.Blocks {
+		if block.Kind == BlockEntry {
+			images = append(images, r.RenderRegion(doc, block.Top, block.Bottom))
+		}
+	}
+	return images
+}
+
+// RenderRegion draws the horizontal band of the document between top and bottom
+func (r *RasterRenderer) RenderRegion(doc *Document, top, bottom float64) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, r.px(doc.Width), r.px(bottom-top)))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	for _, block := range doc.Blocks {
+		for _, pic := range block.Images {
+			r.drawImage(img, pic, top)
+		}
+
+		for _, box := range block.Boxes {
+			if box.Filled {
+				rect := image.Rect(r.px(box.X), r.px(box.Y-top), r.px(box.X+box.W), r.px(box.Y+box.H-top))
+				draw.Draw(img, rect, image.Black, image.Point{}, draw.Src)
+				continue
+			}
+			r.drawLine(img, box.X, box.Y-top, box.X+box.W, box.Y-top, box.LineWidth, box.Dashed)
+			r.drawLine(img, box.X, box.Y+box.H-top, box.X+box.W, box.Y+box.H-top, box.LineWidth, box.Dashed)
+			r.drawLine(img, box.X, box.Y-top, box.X, box.Y+box.H-top, box.LineWidth, box.Dashed)
+			r.drawLine(img, box.X+box.W, box.Y-top, box.X+box.W, box.Y+box.H-top, box.LineWidth, box.Dashed)
+		}
+
+		for _, line := range block.Lines {
+			r.drawLine(img, line.X1, line.Y1-top, line.X2, line.Y2-top, line.Width, line.Dashed)
+		}
+
+		for _, run := range block.Texts {
+			// Runs are positioned by their top edge, the drawer by the baseline of the first font
+			primary := r.face(run.Style, 0, run.Size)
+			if primary == nil {
+				continue
+			}
+			dot := fixed.Point26_6{
+				X: fixed.I(r.px(run.X)),
+				Y: fixed.I(r.px(run.Y-top)) + primary.Metrics().Ascent,
+			}
+
+			for _, part := range r.chain.runs(run.Text, run.Style) {
+				face := r.face(run.Style, part.font, run.Size)
+				if face == nil {
+					continue
+				}
+				drawer := &font.Drawer{Dst: img, Src: image.Black, Face: face, Dot: dot}
+				drawer.DrawString(part.text)
+				dot = drawer.Dot
+			}
+		}
+	}
+
+	return img
+}
+
+// drawLine strokes a line of the given width in mm, with 1mm dashes when dashed
+func (r *RasterRenderer) drawLine(img *image.RGBA, x1, y1, x2, y2, width float64, dashed bool) {
+	thickness := r.px(width)
+	if thickness < 1 {
+		thickness = 1
+	}
+
+	length := math.Hypot(x2-x1, y2-y1)
+	steps := r.px(length)
+	dash := r.px(1.0)
+	for i := 0; i <= steps; i++ {
+		if dashed && dash > 0 && (i/dash)%2 == 1 {
+			continue
+		}
+		t := 0.0
+		if steps > 0 {
+			t = float64(i) / float64(steps)
+		}
+		x := r.px(x1 + (x2-x1)*t)
+		y := r.px(y1 + (y2-y1)*t)
+		rect := image.Rect(x-thickness/2, y-thickness/2, x-thickness/2+thickness, y-thickness/2+thickness)
+		draw.Draw(img, rect, image.Black, image.Point{}, draw.Src)
+	}
+}
+
+// drawImage scales a picture file into its place on the page
+func (r *RasterRenderer) drawImage(img *image.RGBA, pic Image, top float64) {
+	file, err := os.Open(pic.Path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return
+	}
+
+	rect := image.Rect(r.px(pic.X), r.px(pic.Y-top), r.px(pic.X+pic.W), r.px(pic.Y+pic.H-top))
+	draw.ApproxBiLinear.Scale(img, rect, src, src.Bounds(), draw.Over, nil)
+}
+
+// ExportPNG lays out the manifest and saves it as PNG at the DPI of the config, either
+// as one tall image or one image per entry
+func ExportPNG(manifest *Manifest, config *PDFConfig, perEntry bool) ([]string, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid PDF config: %v", err)
+	}
+
+	fonts, err := LoadFonts(config.Fonts)
+	if err != nil {
+		return nil, err
+	}
+
+	renderer, err := NewRasterRenderer(config.DPI, fonts)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := BuildLayout(manifest, config, renderer)
+
+	var images []image.Image
+	if perEntry {
+		images = renderer.RenderEntries(doc)
+	} else {
+		images = []image.Image{renderer.RenderRegion(doc, 0, doc.Height)}
+	}
+	if err := renderer.Err(); err != nil {
+		return nil, err
+	}
+
+	// Encode every image first so the files of one export share a name
+	suffixes := make([]string, len(images))
+	data := make([][]byte, len(images))
+	for i, img := range images {
+		suffixes[i] = ".png"
+		if perEntry {
+			suffixes[i] = fmt.Sprintf("_%02d.png", i+1)
+		}
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("could not encode image: %v", err)
+		}
+		data[i] = buf.Bytes()
+	}
+
+	return saveFiles(config.Output, "fanatitra", manifest, suffixes, data)
+}
@@ -0,0 +1,86 @@
+package pdf
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// decodePNG reads a saved image
+func decodePNG(t *testing.T, path string) image.Image {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	img, err := png.Decode(file)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return img
+}
+
+func TestExportPNG(t *testing.T) {
+	manifest := &Manifest{
+		Zone: "Analakely",
+		Date: time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local),
+		Entries: []DeliveryEntry{
+			{ID: "001", Name: "Rakoto", Address: "Lot II A 12", Items: "12+3"},
+			{ID: "002", Name: "Rabe", Items: "20"},
+			{ID: "003", Name: "Rasoa", Items: "7"},
+		},
+	}
+
+	for _, test := range []struct {
+		dpi   float64
+		width int
+	}{
+		{200, 614}, // 78mm at 200 DPI
+		{300, 921},
+	} {
+		config := DefaultConfig()
+		config.Output = t.TempDir()
+		config.DPI = test.dpi
+
+		paths, err := ExportPNG(manifest, config, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{filepath.Join(config.Output, "fanatitra_Analakely_2026-10-19.png")}; len(paths) != 1 || paths[0] != want[0] {
+			t.Fatalf("saved %q, want %q", paths, want)
+		}
+		whole := decodePNG(t, paths[0])
+		if width := whole.Bounds().Dx(); width != test.width {
+			t.Errorf("%v DPI: image %dpx wide, want %dpx", test.dpi, width, test.width)
+		}
+
+		paths, err = ExportPNG(manifest, config, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(paths) != len(manifest.Entries) {
+			t.Fatalf("%v DPI: %d images per entry, want %d", test.dpi, len(paths), len(manifest.Entries))
+		}
+		for i, path := range paths {
+			if want := filepath.Join(config.Output, fmt.Sprintf("fanatitra_Analakely_2026-10-19_%02d.png", i+1)); path != want {
+				t.Errorf("entry image %s, want %s", path, want)
+			}
+			img := decodePNG(t, path)
+			if img.Bounds().Dx() != test.width || img.Bounds().Dy() >= whole.Bounds().Dy() {
+				t.Errorf("entry image %s is %v, want %dpx wide and shorter than the list", path, img.Bounds().Size(), test.width)
+			}
+		}
+	}
+
+	config := DefaultConfig()
+	config.Output = t.TempDir()
+	config.DPI = 0
+	if _, err := ExportPNG(manifest, config, false); err == nil {
+		t.Error("a DPI of 0 was accepted")
+	}
+}
@@ -0,0 +1,218 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Settings is the layout the shop saved: a config built on a paper preset, the
+// overrides of zones that need a different one and the label stock it prints on
+type Settings struct {
+	Paper      string // Paper preset the config starts from, the 78mm roll when empty
+	Config     *PDFConfig
+	LabelSheet *LabelSheetConfig
+	zones      map[string]map[string]any
+}
+
+// settingsFile is how settings are written: the config keys at the top level, the
+// [label_sheet] table and one [zone."name"] table of overrides per zone
+type settingsFile struct {
+	Paper string `toml:"paper,omitempty"`
+	*PDFConfig
+	LabelSheet *LabelSheetConfig         `toml:"label_sheet"`
+	Zones      map[string]map[string]any `toml:"zone,omitempty"`
+}
+
+// DefaultSettings returns settings with the default config, the default label sheet and
+// no zone overrides
+func DefaultSettings() *Settings {
+	return &Settings{Config: DefaultConfig(), LabelSheet: DefaultLabelSheetConfig()}
+}
+
+// ParseSettings decodes and validates TOML settings. Unknown keys do not fail the
+// parse, they are returned as warnings.
+func ParseSettings(data string) (*Settings, []string, error) {
+	var paper struct {
+		Paper string `toml:"paper"`
+	}
+	if _, err := toml.Decode(data, &paper); err != nil {
+		return nil, nil, fmt.Errorf("could not parse settings: %v", err)
+	}
+
+	config := DefaultConfig()
+	if paper.Paper != "" {
+		preset, err := PaperPreset(paper.Paper)
+		if err != nil {
+			return nil, nil, err
+		}
+		config = preset
+	}
+
+	file := struct {
+		Paper string `toml:"paper"`
+		*PDFConfig
+		LabelSheet *LabelSheetConfig         `toml:"label_sheet"`
+		Zones      map[string]toml.Primitive `toml:"zone"`
+	}{PDFConfig: config, LabelSheet: DefaultLabelSheetConfig()}
+	md, err := toml.Decode(data, &file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse settings: %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if err := file.LabelSheet.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("label_sheet: %v", err)
+	}
+
+	// Decode every zone on top of the base config to validate it and mark its keys as known
+	for zone, primitive := range file.Zones {
+		zoneConfig := *config
+		if err := md.PrimitiveDecode(primitive, &zoneConfig); err != nil {
+			return nil, nil, fmt.Errorf("zone %q: %v", zone, err)
+		}
+		if err := zoneConfig.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("zone %q: %v", zone, err)
+		}
+	}
+
+	var warnings []string
+	for _, key := range md.Undecoded() {
+		warnings = append(warnings, fmt.Sprintf("unknown setting %s", key.String()))
+	}
+
+	settings := &Settings{
+		Paper:      strings.ToLower(paper.Paper),
+		Config:     config,
+		LabelSheet: file.LabelSheet,
+		zones:      make(map[string]map[string]any),
+	}
+	for zone, primitive := range file.Zones {
+		var overrides map[string]any
+		if err := md.PrimitiveDecode(primitive, &overrides); err != nil {
+			return nil, nil, fmt.Errorf("zone %q: %v", zone, err)
+		}
+		settings.zones[zone] = overrides
+	}
+
+	return settings, warnings, nil
+}
+
+// LoadSettings reads TOML settings from a file
+func LoadSettings(path string) (*Settings, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read settings %s: %v", path, err)
+	}
+
+	settings, warnings, err := ParseSettings(string(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for i, warning := range warnings {
+		warnings[i] = fmt.Sprintf("%s: %s", path, warning)
+	}
+	return settings, warnings, nil
+}
+
+// UserSettingsPath returns where the shop's settings are looked up and saved
+func UserSettingsPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get config directory: %v", err)
+	}
+	return filepath.Join(configDir, "pdfgen", "config.toml"), nil
+}
+
+// LoadUserSettings loads the shop's settings, or the default ones when there are none
+func LoadUserSettings() (*Settings, []string, error) {
+	path, err := UserSettingsPath()
+	if err != nil {
+		return DefaultSettings(), nil, err
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return DefaultSettings(), nil, nil
+	}
+
+	settings, warnings, err := LoadSettings(path)
+	if err != nil {
+		return DefaultSettings(), nil, err
+	}
+	return settings, warnings, nil
+}
+
+// SaveUserSettings writes the settings where LoadUserSettings finds them
+func SaveUserSettings(settings *Settings) error {
+	path, err := UserSettingsPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not create config directory: %v", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create %s: %v", path, err)
+	}
+	defer file.Close()
+
+	if err := settings.Write(file); err != nil {
+		return fmt.Errorf("could not write %s: %v", path, err)
+	}
+	return file.Close()
+}
+
+// Write encodes the settings as TOML
+func (s *Settings) Write(w io.Writer) error {
+	return toml.NewEncoder(w).Encode(settingsFile{
+		Paper:      s.Paper,
+		PDFConfig:  s.Config,
+		LabelSheet: s.LabelSheet,
+		Zones:      s.zones,
+	})
+}
+
+// ForZone returns the config for a zone: the base config with the zone's overrides
+// applied, zone names being compared without regard to case
+func (s *Settings) ForZone(zone string) *PDFConfig {
+	config := *s.Config
+	for name, overrides := range s.zones {
+		if !strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(zone)) {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(overrides); err == nil {
+			// The overrides were checked against PDFConfig when the settings were parsed
+			toml.Decode(buf.String(), &config)
+		}
+		break
+	}
+	return &config
+}
+
+// LabelSheetFor returns the label sheet for a zone: the saved label stock, with the
+// captions, fonts and output directory of the zone's config
+func (s *Settings) LabelSheetFor(zone string) *LabelSheetConfig {
+	sheet := DefaultLabelSheetConfig()
+	if s.LabelSheet != nil {
+		copied := *s.LabelSheet
+		sheet = &copied
+	}
+
+	config := s.ForZone(zone)
+	sheet.Labels = config.Labels
+	sheet.Fonts = config.Fonts
+	sheet.MissingGlyph = config.MissingGlyph
+	sheet.Output = config.Output
+	return sheet
+}
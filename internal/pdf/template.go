@@ -0,0 +1,134 @@
+package pdf
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+//go:embed templates/default.toml
+var defaultTemplate string
+
+// TemplateBlock is one section of an entry as declared in a template. Size, Bold and
+// Italic override the typography role of the block when set.
+type TemplateBlock struct {
+	Type   string  `toml:"type"`
+	Size   float64 `toml:"size"`
+	Bold   *bool   `toml:"bold"`
+	Italic *bool   `toml:"italic"`
+	Label  string  `toml:"label"`
+}
+
+// Template lists the sections printed for every entry, in order
+type Template struct {
+	Blocks []TemplateBlock `toml:"block"`
+}
+
+// templateBlockTypes are the section types the layout knows how to place
+var templateBlockTypes = []string{"heading", "id", "address", "phone", "items", "notes", "box"}
+
+// DefaultTemplate returns the built-in template matching the original manifest layout
+func DefaultTemplate() *Template {
+	tmpl, err := ParseTemplate(defaultTemplate)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default template: %v", err))
+	}
+	return tmpl
+}
+
+// ParseTemplate decodes and validates a TOML template
+func ParseTemplate(data string) (*Template, error) {
+	var tmpl Template
+	md, err := toml.Decode(data, &tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse template: %v", err)
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		var keys []string
+		for _, key := range undecoded {
+			keys = append(keys, key.String())
+		}
+		return nil, fmt.Errorf("unknown template keys: %s", strings.Join(keys, ", "))
+	}
+
+	if err := tmpl.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &tmpl, nil
+}
+
+// LoadTemplate reads a TOML template from a file
+func LoadTemplate(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read template %s: %v", path, err)
+	}
+
+	tmpl, err := ParseTemplate(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return tmpl, nil
+}
+
+// UserTemplatePath returns where the shop's own template is looked up
+func UserTemplatePath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("could not get config directory: %v", err)
+	}
+	return filepath.Join(configDir, "pdfgen", "template.toml"), nil
+}
+
+// LoadUserTemplate loads the shop's template, or the default one when there is none
+func LoadUserTemplate() (*Template, error) {
+	path, err := UserTemplatePath()
+	if err != nil {
+		return DefaultTemplate(), err
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return DefaultTemplate(), nil
+	}
+
+	tmpl, err := LoadTemplate(path)
+	if err != nil {
+		return DefaultTemplate(), err
+	}
+	return tmpl, nil
+}
+
+// Validate checks that every block has a known type and no negative font size
+func (t *Template) Validate() error {
+	for i, block := range t.Blocks {
+		known := false
+		for _, blockType := range templateBlockTypes {
+			if block.Type == blockType {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("block %d: unknown type %q, expected one of %s", i+1, block.Type, strings.Join(templateBlockTypes, ", "))
+		}
+
+		if block.Size < 0 {
+			return fmt.Errorf("block %d (%s): size cannot be negative, got %v", i+1, block.Type, block.Size)
+		}
+	}
+	return nil
+}
+
+// label returns the block's caption, or fallback when the template does not set one
+func (b TemplateBlock) label(fallback string) string {
+	if b.Label != "" {
+		return b.Label
+	}
+	return fallback
+}
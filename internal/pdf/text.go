@@ -0,0 +1,206 @@
+package pdf
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// TextFormat selects the flavor of a text manifest
+type TextFormat int
+
+const (
+	TextPlain TextFormat = iota
+	TextMarkdown
+)
+
+// TextConfig controls how a manifest is written as text
+type TextConfig struct {
+	Format TextFormat
+	Width  int // Line width in characters for plain text, 0 disables wrapping
+}
+
+// DefaultTextConfig returns plain text sized for a phone screen in a monospace font
+func DefaultTextConfig() *TextConfig {
+	return &TextConfig{
+		Format: TextPlain,
+		Width:  32,
+	}
+}
+
+// FormatText writes the header, entries, items, totals and notes of a manifest as
+// plain text or WhatsApp-flavored Markdown
+func FormatText(manifest *Manifest, config *PDFConfig, text *TextConfig) string {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if text == nil {
+		text = DefaultTextConfig()
+	}
+
+	if text.Format == TextMarkdown {
+		return formatMarkdown(manifest, &config.Labels)
+	}
+	return formatPlain(manifest, &config.Labels, text.Width)
+}
+
+// formatPlain writes the manifest as fixed-width text
+func formatPlain(manifest *Manifest, labels *Labels, width int) string {
+	var sb strings.Builder
+	rule := func(char string) {
+		if width > 0 {
+			sb.WriteString(strings.Repeat(char, width) + "\n")
+		}
+	}
+
+	for _, line := range wrapColumns(manifest.Zone, width) {
+		sb.WriteString(line + "\n")
+	}
+	for _, line := range manifest.headerLines(labels) {
+		writeIndented(&sb, "", "  ", line, width)
+	}
+	for _, note := range strings.Split(manifest.Notes, "\n") {
+		if strings.TrimSpace(note) != "" {
+			writeIndented(&sb, "", "", stripEmphasis(note), width)
+		}
+	}
+	rule("=")
+
+	entries := manifest.Entries
+	for i, entry := range entries {
+		// Name on the left, total on the right of the first line
+		total := FormatAmount(entry.CalculateTotal(), labels)
+		nameWidth := 0
+		if width > 0 {
+			nameWidth = width - utf8.RuneCountInString(total) - 1
+		}
+		nameLines := wrapColumns(entry.Name, nameWidth)
+		if len(nameLines) == 0 {
+			nameLines = []string{""}
+		}
+		sb.WriteString(padRight(nameLines[0], total, width) + "\n")
+		for _, line := range nameLines[1:] {
+			sb.WriteString(line + "\n")
+		}
+
+		sb.WriteString(FormatID(entry.ID, labels) + "\n")
+		writeIndented(&sb, "> ", "  ", entry.Address, width)
+
+		phone := entry.Phone
+		if phone == "" {
+			phone = labels.NoPhone
+		}
+		sb.WriteString("# " + phone + "\n")
+
+		writeIndented(&sb, labels.Items+" ", "  ", strings.Join(formatItems(entry, labels), ", "), width)
+
+		if entry.Notes != "" {
+			writeIndented(&sb, labels.Notes+" ", "  ", stripEmphasis(entry.Notes), width)
+		}
+
+		if i < len(entries)-1 {
+			rule("-")
+		}
+	}
+
+	rule("=")
+	sb.WriteString(manifest.Day().Format("02/01/2006") + "\n")
+	return sb.String()
+}
+
+// formatMarkdown writes the manifest with WhatsApp emphasis and lists
+func formatMarkdown(manifest *Manifest, labels *Labels) string {
+	var sb strings.Builder
+
+	sb.WriteString("*" + manifest.Zone + "*\n")
+	sb.WriteString("_" + manifest.Day().Format("02/01/2006") + "_\n")
+	if who := joinDetails(manifest.Courier, manifest.CourierPhone); who != "" {
+		sb.WriteString("_" + labels.Courier + "_ " + who + "\n")
+	}
+	if shop := joinDetails(manifest.Shop, manifest.ShopContact); shop != "" {
+		sb.WriteString("_" + labels.Shop + "_ " + shop + "\n")
+	}
+	for _, note := range strings.Split(manifest.Notes, "\n") {
+		if strings.TrimSpace(note) != "" {
+			sb.WriteString(note + "\n")
+		}
+	}
+
+	for _, entry := range manifest.Entries {
+		sb.WriteString("\n")
+		sb.WriteString("*" + entry.Name + "* - *" + FormatAmount(entry.CalculateTotal(), labels) + "*\n")
+		sb.WriteString(FormatID(entry.ID, labels) + "\n")
+		if entry.Address != "" {
+			sb.WriteString(entry.Address + "\n")
+		}
+
+		if entry.Phone == "" {
+			sb.WriteString(labels.NoPhone + "\n")
+		} else {
+			sb.WriteString(entry.Phone + "\n")
+		}
+
+		sb.WriteString(labels.Items + "\n")
+		for _, item := range formatItems(entry, labels) {
+			sb.WriteString("- " + item + "\n")
+		}
+
+		if entry.Notes != "" {
+			sb.WriteString("_" + labels.Notes + "_ " + entry.Notes + "\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// formatItems returns the readable items of an entry, skipping prices that cannot be read
+func formatItems(entry DeliveryEntry, labels *Labels) []string {
+	var items []string
+	for _, price := range strings.Split(entry.Items, "+") {
+		if item, ok := FormatItem(price, labels); ok {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// wrapColumns wraps text to a number of characters, or keeps it on one line when width is 0
+func wrapColumns(text string, width int) []string {
+	if width <= 0 {
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		return []string{strings.Join(strings.Fields(text), " ")}
+	}
+
+	return wrapText(func(s string) float64 {
+		return float64(utf8.RuneCountInString(s))
+	}, text, float64(width))
+}
+
+// writeIndented writes text after a prefix, indenting the continuation lines
+func writeIndented(sb *strings.Builder, prefix, indent, text string, width int) {
+	available := 0
+	if width > 0 {
+		available = width - utf8.RuneCountInString(prefix)
+	}
+
+	lines := wrapColumns(text, available)
+	if len(lines) == 0 {
+		sb.WriteString(strings.TrimRight(prefix, " ") + "\n")
+		return
+	}
+
+	sb.WriteString(prefix + lines[0] + "\n")
+	for _, line := range lines[1:] {
+		sb.WriteString(indent + line + "\n")
+	}
+}
+
+// padRight places right at the end of a line of width characters that starts with left
+func padRight(left, right string, width int) string {
+	gap := width - utf8.RuneCountInString(left) - utf8.RuneCountInString(right)
+	if gap < 1 {
+		gap = 1
+	}
+	return left + strings.Repeat(" ", gap) + right
+}
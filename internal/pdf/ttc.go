@@ -0,0 +1,35 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// isCollection reports whether font data is a TrueType collection (.ttc)
+func isCollection(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("ttcf"))
+}
+
+// extractCollectionFont copies the face at index out of a TrueType collection into a
+// standalone TrueType font, the only kind gopdf can load
+func extractCollectionFont(data []byte, index int) ([]byte, error) {
+	if len(data) < 12 || !isCollection(data) {
+		return nil, fmt.Errorf("not a font collection")
+	}
+
+	numFonts := int(binary.BigEndian.Uint32(data[8:]))
+	if index < 0 || index >= numFonts {
+		return nil, fmt.Errorf("font collection has %d faces, no face %d", numFonts, index)
+	}
+	if len(data) < 12+4*numFonts {
+		return nil, fmt.Errorf("font collection header is truncated")
+	}
+
+	offset := int(binary.BigEndian.Uint32(data[12+4*index:]))
+	version, tables, err := readFontTables(data, offset)
+	if err != nil {
+		return nil, fmt.Errorf("face %d: %v", index, err)
+	}
+	return writeFont(version, tables), nil
+}
@@ -0,0 +1,150 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/signintech/gopdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+// buildCollection joins fonts into a TrueType collection, moving the table offsets of
+// each font by where it lands in the file
+func buildCollection(fonts ...[]byte) []byte {
+	var out bytes.Buffer
+	out.WriteString("ttcf")
+	binary.Write(&out, binary.BigEndian, []uint32{0x00010000, uint32(len(fonts))})
+	offsets := out.Len()
+	out.Write(make([]byte, 4*len(fonts)))
+
+	for i, font := range fonts {
+		start := out.Len()
+		binary.BigEndian.PutUint32(out.Bytes()[offsets+4*i:], uint32(start))
+		out.Write(font)
+		for out.Len()%4 != 0 {
+			out.WriteByte(0)
+		}
+
+		copied := out.Bytes()[start:]
+		numTables := int(binary.BigEndian.Uint16(copied[4:]))
+		for t := 0; t < numTables; t++ {
+			record := copied[12+16*t:]
+			binary.BigEndian.PutUint32(record[8:], binary.BigEndian.Uint32(record[8:])+uint32(start))
+		}
+	}
+	return out.Bytes()
+}
+
+// loadPDFFont checks that gopdf can embed a font and draw with it
+func loadPDFFont(t *testing.T, data []byte) {
+	t.Helper()
+	pdf := &gopdf.GoPdf{}
+	pdf.Start(gopdf.Config{PageSize: *gopdf.PageSizeA4, Unit: gopdf.Unit_MM})
+	pdf.AddPage()
+	if err := pdf.AddTTFFontData("test", data); err != nil {
+		t.Fatalf("gopdf could not load the font: %v", err)
+	}
+	if err := pdf.SetFont("test", "", 12); err != nil {
+		t.Fatal(err)
+	}
+	if err := pdf.Cell(nil, "Ambohijanahary"); err != nil {
+		t.Fatalf("gopdf could not draw with the font: %v", err)
+	}
+	if _, err := pdf.GetBytesPdfReturnErr(); err != nil {
+		t.Fatalf("gopdf could not write the font: %v", err)
+	}
+}
+
+func TestExtractCollectionFont(t *testing.T) {
+	collection := buildCollection(goregular.TTF, gobold.TTF)
+	if !isCollection(collection) {
+		t.Fatal("built collection is not recognized")
+	}
+
+	for index, face := range []struct {
+		data      []byte
+		subfamily string
+	}{
+		{goregular.TTF, "Regular"},
+		{gobold.TTF, "Bold"},
+	} {
+		data, err := extractCollectionFont(collection, index)
+		if err != nil {
+			t.Fatalf("face %d: %v", index, err)
+		}
+		font, err := sfnt.Parse(data)
+		if err != nil {
+			t.Fatalf("face %d does not parse: %v", index, err)
+		}
+		if got := fontName(font, sfnt.NameIDSubfamily); got != face.subfamily {
+			t.Errorf("face %d is %q, want %q", index, got, face.subfamily)
+		}
+		loadPDFFont(t, data)
+
+		// The tables are copied unchanged but for the head checksum adjustment
+		_, want, err := readFontTables(face.data, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, got, err := readFontTables(data, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, table := range want {
+			gotData := findFontTable(got, table.tag)
+			if table.tag == "head" && len(gotData) >= 12 {
+				gotData = append(append(bytes.Clone(gotData[:8]), table.data[8:12]...), gotData[12:]...)
+			}
+			if !bytes.Equal(gotData, table.data) {
+				t.Errorf("face %d: table %q changed", index, table.tag)
+			}
+		}
+
+		// The checksum adjustment makes the whole file add up to the magic number
+		if sum := tableChecksum(data); sum != 0xB1B0AFBA {
+			t.Errorf("face %d: file checksum %#x, want 0xb1b0afba", index, sum)
+		}
+	}
+}
+
+func TestExtractCollectionFontRejectsBrokenCollections(t *testing.T) {
+	collection := buildCollection(goregular.TTF, gobold.TTF)
+
+	for _, index := range []int{-1, 2, 5} {
+		if _, err := extractCollectionFont(collection, index); err == nil {
+			t.Errorf("face %d of 2 was accepted", index)
+		}
+	}
+
+	for _, size := range []int{0, 4, 11, 12 + 4, 12 + 4*2 + 10, 12 + 4*2 + 12 + 16*3} {
+		if _, err := extractCollectionFont(collection[:size], 0); err == nil {
+			t.Errorf("collection cut at %d bytes was accepted", size)
+		}
+	}
+	if _, err := extractCollectionFont(goregular.TTF, 0); err == nil {
+		t.Error("a single font was read as a collection")
+	}
+
+	// A face or a table placed past the end of the file
+	broken := bytes.Clone(collection)
+	binary.BigEndian.PutUint32(broken[12+4:], uint32(len(broken)))
+	if _, err := extractCollectionFont(broken, 1); err == nil {
+		t.Error("face out of the file was accepted")
+	}
+	broken = bytes.Clone(collection)
+	face := int(binary.BigEndian.Uint32(broken[12:]))
+	binary.BigEndian.PutUint32(broken[face+12+8:], uint32(len(broken)-4))
+	if _, err := extractCollectionFont(broken, 0); err == nil {
+		t.Error("table out of the file was accepted")
+	}
+
+	// A face count larger than the header
+	broken = bytes.Clone(collection[:12+4*2])
+	binary.BigEndian.PutUint32(broken[8:], 1<<30)
+	if _, err := extractCollectionFont(broken, 1<<29); err == nil {
+		t.Error("huge face count was accepted")
+	}
+}
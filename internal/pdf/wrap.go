@@ -0,0 +1,232 @@
+package pdf
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// breakAfter are the characters a word may be broken after, as in "45 bis/Ambohijanahary"
+const breakAfter = "/-,;"
+
+// wrapText splits text into lines no wider than maxWidth according to measure
+func wrapText(measure func(string) float64, text string, maxWidth float64) []string {
+	return wrapLines(measure, text, maxWidth, false)
+}
+
+// wrapLines splits text into lines no wider than maxWidth according to measure. Lines
+// break between words or after punctuation such as "/"; a word wider than a line is
+// split between grapheme clusters, with a hyphen when hyphenate is set.
+func wrapLines(measure func(string) float64, text string, maxWidth float64, hyphenate bool) []string {
+	var lines []string
+	spans := []textSpan{{Text: text}}
+	for _, line := range wrapSpans(func(s string, _ FontStyle) float64 { return measure(s) }, spans, maxWidth, hyphenate) {
+		lines = append(lines, line[0].Text)
+	}
+	return lines
+}
+
+// wrapSpans splits styled text into lines no wider than maxWidth, breaking it as
+// wrapLines does. measure returns the width of a text in a style.
+func wrapSpans(measure func(string, FontStyle) float64, spans []textSpan, maxWidth float64, hyphenate bool) [][]textSpan {
+	width := func(line []textSpan) float64 {
+		total := 0.0
+		for _, span := range line {
+			total += measure(span.Text, span.Style)
+		}
+		return total
+	}
+
+	var lines [][]textSpan
+	var line []textSpan
+
+	for _, word := range spanWords(spans) {
+		for i, piece := range word {
+			candidate := []textSpan{piece}
+			if len(line) > 0 {
+				// Pieces of the same word are joined without a space
+				candidate = slices.Clone(line)
+				if i == 0 {
+					candidate = appendSpan(candidate, " ", candidate[len(candidate)-1].Style)
+				}
+				candidate = appendSpan(candidate, piece.Text, piece.Style)
+			}
+
+			if width(candidate) <= maxWidth {
+				line = candidate
+				continue
+			}
+
+			if len(line) > 0 {
+				lines = append(lines, line)
+				line = nil
+			}
+
+			// Split pieces that do not fit on a line of their own
+			measurePiece := func(s string) float64 {
+				return measure(s, piece.Style)
+			}
+			for piece.Text != "" && measurePiece(piece.Text) > maxWidth {
+				head, tail := splitWord(measurePiece, piece.Text, maxWidth, hyphenate)
+				lines = append(lines, []textSpan{{Text: head, Style: piece.Style}})
+				piece.Text = tail
+			}
+			if piece.Text != "" {
+				line = []textSpan{piece}
+			}
+		}
+	}
+
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// spanWords splits styled text into words, each made of the pieces that may be placed
+// on different lines: the parts in different styles, cut again after break characters
+func spanWords(spans []textSpan) [][]textSpan {
+	var words [][]textSpan
+	var word []textSpan
+	addPieces := func(text string, style FontStyle) {
+		for _, piece := range wordPieces(text) {
+			word = append(word, textSpan{Text: piece, Style: style})
+		}
+	}
+
+	for _, span := range spans {
+		start := -1
+		for i, r := range span.Text {
+			if !unicode.IsSpace(r) {
+				if start < 0 {
+					start = i
+				}
+				continue
+			}
+			if start >= 0 {
+				addPieces(span.Text[start:i], span.Style)
+				start = -1
+			}
+			if len(word) > 0 {
+				words = append(words, word)
+				word = nil
+			}
+		}
+		if start >= 0 {
+			addPieces(span.Text[start:], span.Style)
+		}
+	}
+	if len(word) > 0 {
+		words = append(words, word)
+	}
+	return words
+}
+
+// wordPieces splits a word after each break character that is followed by more text
+func wordPieces(word string) []string {
+	var pieces []string
+	start := 0
+	for i, r := range word {
+		end := i + utf8.RuneLen(r)
+		if strings.ContainsRune(breakAfter, r) && end > start+utf8.RuneLen(r) && end < len(word) {
+			pieces = append(pieces, word[start:end])
+			start = end
+		}
+	}
+	return append(pieces, word[start:])
+}
+
+// splitWord returns the longest start of a word that fits maxWidth, with a hyphen when
+// hyphenate is set, and the rest of the word. At least one grapheme cluster is taken
+// so wrapping always progresses.
+func splitWord(measure func(string) float64, word string, maxWidth float64, hyphenate bool) (string, string) {
+	clusters := graphemes(word)
+	if len(clusters) < 2 {
+		return word, ""
+	}
+	hyphen := ""
+	if hyphenate {
+		hyphen = "-"
+	}
+	prefix := func(n int) string {
+		return strings.Join(clusters[:n], "")
+	}
+
+	// Binary search for the number of clusters that fits, measuring log(n) prefixes
+	low, high := 1, len(clusters)-1
+	fit := 1
+	for low <= high {
+		mid := (low + high) / 2
+		if measure(prefix(mid)+hyphen) <= maxWidth {
+			fit = mid
+			low = mid + 1
+		} else {
+			high = mid - 1
+		}
+	}
+
+	if hyphenate {
+		fit = syllableBreak(clusters, fit)
+	}
+	return prefix(fit) + hyphen, strings.Join(clusters[fit:], "")
+}
+
+// syllableBreak moves a break back to the nearest vowel-consonant-vowel boundary, the
+// usual place to hyphenate Malagasy and French words, if one lies within the last half
+func syllableBreak(clusters []string, fit int) int {
+	for n := fit; n > fit/2 && n > 1; n-- {
+		if n+1 < len(clusters) && isVowel(clusters[n-1]) && !isVowel(clusters[n]) && isVowel(clusters[n+1]) {
+			return n
+		}
+	}
+	return fit
+}
+
+// isVowel reports whether a grapheme cluster starts with a vowel, accented or not
+func isVowel(cluster string) bool {
+	r, _ := utf8.DecodeRuneInString(cluster)
+	return strings.ContainsRune("aeiouyàâäéèêëìîïòôöùûüAEIOUYÀÂÄÉÈÊËÌÎÏÒÔÖÙÛÜ", r)
+}
+
+// graphemes splits text into user-perceived characters: a base rune with the combining
+// marks, variation selectors, skin tone modifiers and zero width joiner sequences that
+// follow it, or a pair of regional indicators forming a flag
+func graphemes(text string) []string {
+	var clusters []string
+	start := 0
+	joined := false
+	regional := false
+	for i, r := range text {
+		if i == start {
+			regional = isRegionalIndicator(r)
+			continue
+		}
+
+		extends := joined ||
+			unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+			r == '\u200d' ||
+			(r >= '\ufe00' && r <= '\ufe0f') ||
+			(r >= 0x1f3fb && r <= 0x1f3ff) ||
+			(regional && isRegionalIndicator(r))
+		if !extends {
+			clusters = append(clusters, text[start:i])
+			start = i
+			regional = isRegionalIndicator(r)
+		} else if isRegionalIndicator(r) {
+			// A flag is exactly two indicators
+			regional = false
+		}
+		joined = r == '\u200d'
+	}
+	if start < len(text) {
+		clusters = append(clusters, text[start:])
+	}
+	return clusters
+}
+
+// isRegionalIndicator reports whether r is one of the letters flags are made of
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
@@ -0,0 +1,108 @@
+package pdf
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// runeWidth measures text one unit per rune, so a width in units is a number of runes
+func runeWidth(text string) float64 {
+	return float64(utf8.RuneCountInString(text))
+}
+
+func TestGraphemes(t *testing.T) {
+	for _, test := range []struct {
+		text string
+		want []string
+	}{
+		{"Ambôhy", []string{"A", "m", "b", "ô", "h", "y"}},
+		{"Ambo\u0302hy", []string{"A", "m", "b", "o\u0302", "h", "y"}},
+		{"été", []string{"é", "t", "é"}},
+		{"e\u0301te\u0301", []string{"e\u0301", "t", "e\u0301"}},
+		{"👨‍👩‍👧👍🏽", []string{"👨‍👩‍👧", "👍🏽"}},
+		{"🇲🇬🇫🇷", []string{"🇲🇬", "🇫🇷"}},
+		{"🇲🇬x", []string{"🇲🇬", "x"}},
+		{"❤️!", []string{"❤️", "!"}},
+		{"", nil},
+	} {
+		if got := graphemes(test.text); !slices.Equal(got, test.want) {
+			t.Errorf("graphemes(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestWrapLines(t *testing.T) {
+	for _, test := range []struct {
+		text      string
+		width     float64
+		hyphenate bool
+		want      []string
+	}{
+		{"Lot II M 45 bis/Ambohijanahary", 16, false, []string{"Lot II M 45 bis/", "Ambohijanahary"}},
+		{"Lot II M 45 bis/Ambohijanahary", 40, false, []string{"Lot II M 45 bis/Ambohijanahary"}},
+		{"Antsirabe, Ambohimena-Ivato", 12, false, []string{"Antsirabe,", "Ambohimena-", "Ivato"}},
+		{"Andrianampoinimerina", 8, false, []string{"Andriana", "mpoinime", "rina"}},
+		{"Andrianampoinimerina", 8, true, []string{"Andria-", "nampoi-", "nimerina"}},
+		{"  Rakoto   Rabe  ", 20, false, []string{"Rakoto Rabe"}},
+		{"", 10, false, nil},
+	} {
+		got := wrapLines(runeWidth, test.text, test.width, test.hyphenate)
+		if !slices.Equal(got, test.want) {
+			t.Errorf("wrapLines(%q, %v, hyphenate %v) = %q, want %q", test.text, test.width, test.hyphenate, got, test.want)
+		}
+	}
+}
+
+func TestWrapLinesKeepsCharactersWhole(t *testing.T) {
+	for _, text := range []string{
+		"Ambôhitsorôhitra",
+		"Ambo\u0302hitsoro\u0302hitra",
+		"Féténéé",
+		"Fe\u0301te\u0301ne\u0301e\u0301",
+		"👨‍👩‍👧👨‍👩‍👧👍🏽👍🏽",
+		"🇲🇬🇫🇷🇲🇬🇫🇷",
+	} {
+		for _, hyphenate := range []bool{false, true} {
+			for width := 1.0; width <= 6; width++ {
+				lines := wrapLines(runeWidth, text, width, hyphenate)
+
+				// The lines hold the characters of the word in order, each one whole
+				rest := graphemes(text)
+				for _, line := range lines {
+					part := graphemes(strings.TrimSuffix(line, "-"))
+					if len(part) > len(rest) || !slices.Equal(part, rest[:len(part)]) {
+						t.Errorf("%q at %v, hyphenate %v: line %q splits a character", text, width, hyphenate, line)
+						break
+					}
+					rest = rest[len(part):]
+				}
+				if len(rest) > 0 {
+					t.Errorf("%q at %v, hyphenate %v: lines %q lost %q", text, width, hyphenate, lines, rest)
+				}
+			}
+		}
+	}
+}
+
+func TestWrapLinesTooNarrow(t *testing.T) {
+	// Every line takes at least one character, so no width makes wrapping loop
+	for _, width := range []float64{0, -5, 0.5} {
+		for _, hyphenate := range []bool{false, true} {
+			done := make(chan []string, 1)
+			go func() {
+				done <- wrapLines(runeWidth, "Ambohijanahary 🇲🇬", width, hyphenate)
+			}()
+			select {
+			case lines := <-done:
+				if len(lines) != 15 {
+					t.Errorf("width %v, hyphenate %v: %d lines %q, want one per character", width, hyphenate, len(lines), lines)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("width %v, hyphenate %v: wrapping did not end", width, hyphenate)
+			}
+		}
+	}
+}